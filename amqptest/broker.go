@@ -0,0 +1,265 @@
+// Package amqptest provides an in-process AMQP broker for tests.
+//
+// The broker routes messages through direct, fanout, topic and headers exchanges,
+// and bindings between exchanges, the same way RabbitMQ does.
+// It supports consumers with prefetch, acks, nacks and requeue, publisher confirms, mandatory returns,
+// transactions, flow control and connection and channel close notifications.
+// Message TTL, dead lettering, queue length limits and priorities are not supported.
+//
+// Plug it into a Dialer with WithAMQPDial(broker.Dial),
+// and into consumers and publishers with WithInitFunc(amqptest.ConsumerInit) and WithInitFunc(amqptest.PublisherInit).
+// Dialer.Connection and the declare helpers need a real *amqp.Connection and could not be used with the broker.
+package amqptest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/makasim/amqpextra"
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/streadway/amqp"
+)
+
+// Broker is an in-process AMQP broker.
+// It has a single vhost, the objects declared on one connection are visible to all the others.
+type Broker struct {
+	mu sync.Mutex
+
+	exchanges map[string]*exchange
+	queues    map[string]*queue
+	conns     map[*Connection]struct{}
+
+	dialErr error
+	nameSeq uint64
+	tagSeq  uint64
+}
+
+// NewBroker returns a broker with the default exchange and the pre-declared amq.* exchanges.
+func NewBroker() *Broker {
+	b := &Broker{
+		exchanges: make(map[string]*exchange),
+		queues:    make(map[string]*queue),
+		conns:     make(map[*Connection]struct{}),
+	}
+
+	for name, kind := range map[string]string{
+		"":            amqp.ExchangeDirect,
+		"amq.direct":  amqp.ExchangeDirect,
+		"amq.fanout":  amqp.ExchangeFanout,
+		"amq.topic":   amqp.ExchangeTopic,
+		"amq.headers": amqp.ExchangeHeaders,
+		"amq.match":   amqp.ExchangeHeaders,
+	} {
+		b.exchanges[name] = &exchange{name: name, kind: kind, durable: true}
+	}
+
+	return b
+}
+
+// Dial opens a new connection to the broker, it could be passed to amqpextra.WithAMQPDial.
+// It fails with the error set by SetDialError.
+func (b *Broker) Dial(_ string, _ amqp.Config) (amqpextra.AMQPConnection, error) {
+	b.mu.Lock()
+	err := b.dialErr
+	b.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.NewConnection(), nil
+}
+
+// NewConnection opens a new connection to the broker.
+func (b *Broker) NewConnection() *Connection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	conn := &Connection{
+		broker:   b,
+		channels: make(map[*Channel]struct{}),
+	}
+	b.conns[conn] = struct{}{}
+
+	return conn
+}
+
+// ConsumerInit opens a channel on an amqptest connection, it could be passed to consumer.WithInitFunc.
+func ConsumerInit(conn consumer.AMQPConnection) (consumer.AMQPChannel, error) {
+	c, ok := conn.(*Connection)
+	if !ok {
+		return nil, fmt.Errorf("amqptest: connection %T is not an amqptest connection", conn)
+	}
+
+	return c.Channel()
+}
+
+// PublisherInit opens a channel on an amqptest connection, it could be passed to publisher.WithInitFunc.
+func PublisherInit(conn publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+	c, ok := conn.(*Connection)
+	if !ok {
+		return nil, fmt.Errorf("amqptest: connection %T is not an amqptest connection", conn)
+	}
+
+	return c.Channel()
+}
+
+// SetDialError makes Dial fail with err until it is set to nil.
+func (b *Broker) SetDialError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dialErr = err
+}
+
+// SetFlow notifies all the channels to pause or resume publishing, see amqp.Channel.NotifyFlow.
+// Publishing is not blocked by the broker meanwhile.
+func (b *Broker) SetFlow(active bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn := range b.conns {
+		for ch := range conn.channels {
+			ch.notify(event{flow: &active})
+		}
+	}
+}
+
+// CloseConnections drops all the connections with err, as a broker restart does.
+// Connection and channel close listeners receive err.
+func (b *Broker) CloseConnections(err *amqp.Error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn := range b.conns {
+		conn.shutdown(err)
+	}
+}
+
+// Publish routes the message as if it was published by a client without confirms.
+func (b *Broker) Publish(exchange, key string, msg amqp.Publishing) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		return notFound("exchange", exchange)
+	}
+
+	b.deliver(b.route(ex, key, msg.Headers), exchange, key, msg)
+
+	return nil
+}
+
+// QueueInspect returns the number of ready messages and consumers of the queue.
+func (b *Broker) QueueInspect(name string) (amqp.Queue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[name]
+	if !ok {
+		return amqp.Queue{}, notFound("queue", name)
+	}
+
+	return q.state(), nil
+}
+
+// Messages returns the ready messages of the queue, the messages awaiting an ack are not included.
+func (b *Broker) Messages(queue string) []amqp.Publishing {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil
+	}
+
+	msgs := make([]amqp.Publishing, 0, len(q.messages))
+	for _, m := range q.messages {
+		msgs = append(msgs, m.msg)
+	}
+
+	return msgs
+}
+
+func (b *Broker) newName() string {
+	b.nameSeq++
+	return fmt.Sprintf("amq.gen-%d", b.nameSeq)
+}
+
+func (b *Broker) newConsumerTag() string {
+	b.tagSeq++
+	return fmt.Sprintf("amq.ctag-%d", b.tagSeq)
+}
+
+// deliver enqueues the message to the queues and hands it out to their consumers.
+func (b *Broker) deliver(queues []*queue, exchange, key string, msg amqp.Publishing) {
+	for _, q := range queues {
+		q.messages = append(q.messages, message{exchange: exchange, key: key, msg: msg})
+		q.dispatch()
+	}
+}
+
+func (b *Broker) deleteQueue(q *queue) {
+	delete(b.queues, q.name)
+	q.deleted = true
+
+	for _, c := range q.consumers {
+		c.ch.removeConsumer(c)
+		c.ch.notify(event{cancel: &c.tag})
+
+		// the messages the client has not received are gone with the queue.
+		var tags []uint64
+		for _, d := range c.stop() {
+			tags = append(tags, d.DeliveryTag)
+		}
+		c.ch.settleTags(tags, func(u *unacked) {})
+	}
+	q.consumers = nil
+	q.messages = nil
+
+	for _, ex := range b.exchanges {
+		b.removeBindings(ex, func(bd binding) bool {
+			return bd.queue && bd.destination == q.name
+		})
+	}
+}
+
+func (b *Broker) deleteExchange(ex *exchange) {
+	delete(b.exchanges, ex.name)
+
+	for _, other := range b.exchanges {
+		b.removeBindings(other, func(bd binding) bool {
+			return !bd.queue && bd.destination == ex.name
+		})
+	}
+}
+
+// removeBindings removes the bindings of the exchange matching fn,
+// and deletes the exchange if it is auto delete and has no bindings left.
+func (b *Broker) removeBindings(ex *exchange, fn func(bd binding) bool) {
+	removed := false
+	bindings := ex.bindings[:0]
+	for _, bd := range ex.bindings {
+		if fn(bd) {
+			removed = true
+			continue
+		}
+
+		bindings = append(bindings, bd)
+	}
+	ex.bindings = bindings
+
+	if removed && ex.autoDelete && len(ex.bindings) == 0 {
+		b.deleteExchange(ex)
+	}
+}
+
+func notFound(kind, name string) *amqp.Error {
+	return &amqp.Error{
+		Code:   amqp.NotFound,
+		Reason: fmt.Sprintf("NOT_FOUND - no %s '%s' in vhost '/'", kind, name),
+		Server: true,
+	}
+}
@@ -0,0 +1,558 @@
+package amqptest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/makasim/amqpextra"
+	"github.com/makasim/amqpextra/amqptest"
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/logger"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestRouting(main *testing.T) {
+	main.Run("DefaultExchange", func(t *testing.T) {
+		b := amqptest.NewBroker()
+		ch := openChannel(t, b)
+		defer ch.Close()
+
+		_, err := ch.QueueDeclare("theQueue", false, false, false, false, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, b.Publish("", "theQueue", amqp.Publishing{Body: []byte("foo")}))
+		require.NoError(t, b.Publish("", "otherQueue", amqp.Publishing{Body: []byte("bar")}))
+
+		assertBodies(t, b, "theQueue", "foo")
+	})
+
+	main.Run("Direct", func(t *testing.T) {
+		b := amqptest.NewBroker()
+		ch := openChannel(t, b)
+		defer ch.Close()
+
+		declareQueues(t, ch, "fooQueue", "barQueue")
+		require.NoError(t, ch.QueueBind("fooQueue", "foo", "amq.direct", false, nil))
+		require.NoError(t, ch.QueueBind("barQueue", "bar", "amq.direct", false, nil))
+
+		require.NoError(t, b.Publish("amq.direct", "foo", amqp.Publishing{Body: []byte("foo")}))
+		require.NoError(t, b.Publish("amq.direct", "baz", amqp.Publishing{Body: []byte("baz")}))
+
+		assertBodies(t, b, "fooQueue", "foo")
+		assertBodies(t, b, "barQueue")
+	})
+
+	main.Run("Fanout", func(t *testing.T) {
+		b := amqptest.NewBroker()
+		ch := openChannel(t, b)
+		defer ch.Close()
+
+		declareQueues(t, ch, "fooQueue", "barQueue")
+		require.NoError(t, ch.QueueBind("fooQueue", "", "amq.fanout", false, nil))
+		require.NoError(t, ch.QueueBind("barQueue", "", "amq.fanout", false, nil))
+
+		require.NoError(t, b.Publish("amq.fanout", "any", amqp.Publishing{Body: []byte("foo")}))
+
+		assertBodies(t, b, "fooQueue", "foo")
+		assertBodies(t, b, "barQueue", "foo")
+	})
+
+	main.Run("Topic", func(t *testing.T) {
+		b := amqptest.NewBroker()
+		ch := openChannel(t, b)
+		defer ch.Close()
+
+		declareQueues(t, ch, "starQueue", "hashQueue")
+		require.NoError(t, ch.QueueBind("starQueue", "order.*.created", "amq.topic", false, nil))
+		require.NoError(t, ch.QueueBind("hashQueue", "order.#", "amq.topic", false, nil))
+
+		for _, key := range []string{"order.eu.created", "order", "order.eu.us.created", "invoice.eu.created"} {
+			require.NoError(t, b.Publish("amq.topic", key, amqp.Publishing{Body: []byte(key)}))
+		}
+
+		assertBodies(t, b, "starQueue", "order.eu.created")
+		assertBodies(t, b, "hashQueue", "order.eu.created", "order", "order.eu.us.created")
+	})
+
+	main.Run("Headers", func(t *testing.T) {
+		b := amqptest.NewBroker()
+		ch := openChannel(t, b)
+		defer ch.Close()
+
+		declareQueues(t, ch, "allQueue", "anyQueue")
+		require.NoError(t, ch.QueueBind("allQueue", "", "amq.headers", false, amqp.Table{"format": "pdf", "size": 10}))
+		require.NoError(t, ch.QueueBind("anyQueue", "", "amq.headers", false, amqp.Table{"x-match": "any", "format": "pdf", "size": 10}))
+
+		require.NoError(t, b.Publish("amq.headers", "", amqp.Publishing{
+			Headers: amqp.Table{"format": "pdf", "size": int32(10)},
+			Body:    []byte("both"),
+		}))
+		require.NoError(t, b.Publish("amq.headers", "", amqp.Publishing{
+			Headers: amqp.Table{"format": "pdf"},
+			Body:    []byte("format"),
+		}))
+		require.NoError(t, b.Publish("amq.headers", "", amqp.Publishing{
+			Headers: amqp.Table{"format": "zip"},
+			Body:    []byte("none"),
+		}))
+
+		assertBodies(t, b, "allQueue", "both")
+		assertBodies(t, b, "anyQueue", "both", "format")
+	})
+
+	main.Run("ExchangeToExchange", func(t *testing.T) {
+		b := amqptest.NewBroker()
+		ch := openChannel(t, b)
+		defer ch.Close()
+
+		require.NoError(t, ch.ExchangeDeclare("theExchange", "fanout", false, false, false, false, nil))
+		declareQueues(t, ch, "theQueue")
+		require.NoError(t, ch.QueueBind("theQueue", "", "theExchange", false, nil))
+		require.NoError(t, ch.QueueBind("theQueue", "foo", "amq.direct", false, nil))
+		require.NoError(t, ch.ExchangeBind("theExchange", "foo", "amq.direct", false, nil))
+
+		require.NoError(t, b.Publish("amq.direct", "foo", amqp.Publishing{Body: []byte("foo")}))
+
+		assertBodies(t, b, "theQueue", "foo")
+	})
+}
+
+func TestConsume(main *testing.T) {
+	main.Run("AckNackRequeue", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+
+		declareQueues(t, ch, "theQueue")
+		publishBodies(t, b, "theQueue", "foo", "bar", "baz")
+
+		require.NoError(t, ch.Qos(1, 0, false))
+		msgCh, err := ch.Consume("theQueue", "theConsumer", false, false, false, false, nil)
+		require.NoError(t, err)
+
+		foo := receive(t, msgCh)
+		assert.Equal(t, "foo", string(foo.Body))
+		assert.Equal(t, "theConsumer", foo.ConsumerTag)
+		assert.Equal(t, uint64(1), foo.DeliveryTag)
+		assert.False(t, foo.Redelivered)
+		require.NoError(t, foo.Ack(false))
+
+		bar := receive(t, msgCh)
+		require.NoError(t, bar.Nack(false, true))
+
+		bar = receive(t, msgCh)
+		assert.Equal(t, "bar", string(bar.Body))
+		assert.True(t, bar.Redelivered)
+		require.NoError(t, bar.Reject(false))
+
+		baz := receive(t, msgCh)
+		assert.Equal(t, "baz", string(baz.Body))
+		require.NoError(t, baz.Ack(false))
+
+		q, err := b.QueueInspect("theQueue")
+		require.NoError(t, err)
+		assert.Equal(t, amqp.Queue{Name: "theQueue", Consumers: 1}, q)
+
+		require.NoError(t, ch.Close())
+		_, ok := <-msgCh
+		assert.False(t, ok)
+	})
+
+	main.Run("Prefetch", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+		defer ch.Close()
+
+		declareQueues(t, ch, "theQueue")
+		publishBodies(t, b, "theQueue", "foo", "bar", "baz")
+
+		require.NoError(t, ch.Qos(2, 0, false))
+		msgCh, err := ch.Consume("theQueue", "", false, false, false, false, nil)
+		require.NoError(t, err)
+
+		receive(t, msgCh)
+		bar := receive(t, msgCh)
+		assertBodies(t, b, "theQueue", "baz")
+
+		require.NoError(t, bar.Ack(true))
+
+		assert.Equal(t, "baz", string(receive(t, msgCh).Body))
+		assertBodies(t, b, "theQueue")
+	})
+
+	main.Run("RequeueUnackedOnClose", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+
+		declareQueues(t, ch, "theQueue")
+		publishBodies(t, b, "theQueue", "foo", "bar")
+
+		msgCh, err := ch.Consume("theQueue", "", false, false, false, false, nil)
+		require.NoError(t, err)
+		receive(t, msgCh)
+
+		require.NoError(t, ch.Close())
+
+		assertBodies(t, b, "theQueue", "foo", "bar")
+	})
+
+	main.Run("CancelOnQueueDelete", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+		defer ch.Close()
+
+		declareQueues(t, ch, "theQueue")
+		cancelCh := ch.NotifyCancel(make(chan string, 1))
+
+		msgCh, err := ch.Consume("theQueue", "theConsumer", false, false, false, false, nil)
+		require.NoError(t, err)
+
+		_, err = ch.QueueDelete("theQueue", false, false, false)
+		require.NoError(t, err)
+
+		assert.Equal(t, "theConsumer", <-cancelCh)
+		_, ok := <-msgCh
+		assert.False(t, ok)
+	})
+}
+
+func TestPublish(main *testing.T) {
+	main.Run("ConfirmAndReturn", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+		defer ch.Close()
+
+		declareQueues(t, ch, "theQueue")
+		confirmCh := ch.NotifyPublish(make(chan amqp.Confirmation, 2))
+		returnCh := ch.NotifyReturn(make(chan amqp.Return, 1))
+		require.NoError(t, ch.Confirm(false))
+
+		require.NoError(t, ch.Publish("", "theQueue", true, false, amqp.Publishing{Body: []byte("foo")}))
+		require.NoError(t, ch.Publish("", "otherQueue", true, false, amqp.Publishing{Body: []byte("bar")}))
+
+		assert.Equal(t, amqp.Confirmation{DeliveryTag: 1, Ack: true}, <-confirmCh)
+
+		ret := <-returnCh
+		assert.Equal(t, uint16(amqp.NoRoute), ret.ReplyCode)
+		assert.Equal(t, "otherQueue", ret.RoutingKey)
+		assert.Equal(t, "bar", string(ret.Body))
+
+		assert.Equal(t, amqp.Confirmation{DeliveryTag: 2, Ack: true}, <-confirmCh)
+		assertBodies(t, b, "theQueue", "foo")
+	})
+
+	main.Run("Tx", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+		defer ch.Close()
+
+		declareQueues(t, ch, "theQueue")
+		require.NoError(t, ch.Tx())
+
+		require.NoError(t, ch.Publish("", "theQueue", false, false, amqp.Publishing{Body: []byte("foo")}))
+		require.NoError(t, ch.TxRollback())
+		require.NoError(t, ch.Publish("", "theQueue", false, false, amqp.Publishing{Body: []byte("bar")}))
+		assertBodies(t, b, "theQueue")
+
+		require.NoError(t, ch.TxCommit())
+		assertBodies(t, b, "theQueue", "bar")
+	})
+
+	main.Run("Flow", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+		defer ch.Close()
+
+		flowCh := ch.NotifyFlow(make(chan bool, 2))
+
+		b.SetFlow(false)
+		b.SetFlow(true)
+
+		assert.False(t, <-flowCh)
+		assert.True(t, <-flowCh)
+	})
+
+	main.Run("CloseChannelIfExchangeNotFound", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+
+		closeCh := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+		require.NoError(t, ch.Publish("notExist", "", false, false, amqp.Publishing{}))
+
+		amqpErr := <-closeCh
+		require.NotNil(t, amqpErr)
+		assert.Equal(t, amqp.NotFound, amqpErr.Code)
+		assert.Equal(t, "NOT_FOUND - no exchange 'notExist' in vhost '/'", amqpErr.Reason)
+
+		assert.Equal(t, amqp.ErrClosed, ch.Publish("", "", false, false, amqp.Publishing{}))
+	})
+}
+
+func TestDeclare(main *testing.T) {
+	main.Run("InequivalentQueue", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+		defer conn.Close()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+
+		_, err = ch.QueueDeclare("theQueue", true, false, false, false, amqp.Table{"x-message-ttl": 1000})
+		require.NoError(t, err)
+
+		_, err = ch.QueueDeclare("theQueue", true, false, false, false, amqp.Table{"x-message-ttl": int64(1000)})
+		require.NoError(t, err)
+
+		closeCh := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+		_, err = ch.QueueDeclare("theQueue", true, false, false, false, nil)
+		require.EqualError(t, err, `Exception (406) Reason: "PRECONDITION_FAILED - inequivalent arg 'arguments' for queue 'theQueue' in vhost '/'"`)
+		assert.Equal(t, err, <-closeCh)
+	})
+
+	main.Run("ExclusiveQueue", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		b := amqptest.NewBroker()
+		conn := b.NewConnection()
+
+		ch, err := conn.Channel()
+		require.NoError(t, err)
+
+		q, err := ch.QueueDeclare("", false, true, true, false, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "amq.gen-1", q.Name)
+
+		otherConn := b.NewConnection()
+		defer otherConn.Close()
+
+		otherCh, err := otherConn.Channel()
+		require.NoError(t, err)
+
+		_, err = otherCh.QueueDeclarePassive(q.Name, false, true, true, false, nil)
+		require.EqualError(t, err, `Exception (405) Reason: "RESOURCE_LOCKED - cannot obtain exclusive access to locked queue 'amq.gen-1' in vhost '/'"`)
+
+		require.NoError(t, conn.Close())
+
+		_, err = b.QueueInspect(q.Name)
+		require.EqualError(t, err, `Exception (404) Reason: "NOT_FOUND - no queue 'amq.gen-1' in vhost '/'"`)
+	})
+}
+
+func TestCloseConnections(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	b := amqptest.NewBroker()
+	conn := b.NewConnection()
+
+	ch, err := conn.Channel()
+	require.NoError(t, err)
+
+	declareQueues(t, ch, "theQueue")
+	publishBodies(t, b, "theQueue", "foo")
+
+	msgCh, err := ch.Consume("theQueue", "", false, false, false, false, nil)
+	require.NoError(t, err)
+	receive(t, msgCh)
+
+	connCloseCh := conn.NotifyClose(make(chan *amqp.Error, 1))
+	chCloseCh := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	brokerErr := &amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED - broker forced connection closure"}
+	b.CloseConnections(brokerErr)
+
+	assert.Equal(t, brokerErr, <-connCloseCh)
+	assert.Equal(t, brokerErr, <-chCloseCh)
+	_, ok := <-msgCh
+	assert.False(t, ok)
+
+	assertBodies(t, b, "theQueue", "foo")
+	assert.Equal(t, amqp.ErrClosed, conn.Close())
+}
+
+func TestDialerConsumerPublisher(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	b := amqptest.NewBroker()
+
+	d, err := amqpextra.NewDialer(
+		amqpextra.WithURL("amqp://amqptest"),
+		amqpextra.WithAMQPDial(b.Dial),
+		amqpextra.WithRetryPeriod(time.Millisecond*20),
+		amqpextra.WithLogger(logger.Discard),
+	)
+	require.NoError(t, err)
+	defer d.Close()
+
+	gotCh := make(chan string, 2)
+	c, err := d.Consumer(
+		consumer.WithInitFunc(amqptest.ConsumerInit),
+		consumer.WithRetryPeriod(time.Millisecond*20),
+		consumer.WithDeclareQueue("theQueue", true, false, false, false, nil),
+		consumer.WithHandler(consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
+			gotCh <- string(msg.Body)
+			return consumer.Ack
+		})),
+	)
+	require.NoError(t, err)
+	defer c.Close()
+
+	p, err := d.Publisher(
+		publisher.WithInitFunc(amqptest.PublisherInit),
+		publisher.WithRestartSleep(time.Millisecond*20),
+		publisher.WithConfirmation(1),
+	)
+	require.NoError(t, err)
+	defer p.Close()
+
+	consumerStateCh := c.Notify(make(chan consumer.State, 1))
+	publisherStateCh := p.Notify(make(chan publisher.State, 1))
+	waitConsumerReady(t, consumerStateCh)
+	waitPublisherReady(t, publisherStateCh)
+
+	require.NoError(t, p.Publish(publisher.Message{Key: "theQueue", Publishing: amqp.Publishing{Body: []byte("foo")}}))
+	assert.Equal(t, "foo", <-gotCh)
+
+	b.CloseConnections(&amqp.Error{Code: amqp.ConnectionForced, Reason: "CONNECTION_FORCED"})
+
+	// the publisher could use the lost channel till it is notified.
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := p.Publish(publisher.Message{Key: "theQueue", Publishing: amqp.Publishing{Body: []byte("bar")}})
+		if err == nil {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "publish: %s", err)
+
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.Equal(t, "bar", <-gotCh)
+
+	p.Close()
+	<-p.NotifyClosed()
+	c.Close()
+	<-c.NotifyClosed()
+	d.Close()
+	<-d.NotifyClosed()
+}
+
+func openChannel(t *testing.T, b *amqptest.Broker) *amqptest.Channel {
+	ch, err := b.NewConnection().Channel()
+	require.NoError(t, err)
+
+	return ch
+}
+
+func declareQueues(t *testing.T, ch *amqptest.Channel, names ...string) {
+	for _, name := range names {
+		_, err := ch.QueueDeclare(name, false, false, false, false, nil)
+		require.NoError(t, err)
+	}
+}
+
+func publishBodies(t *testing.T, b *amqptest.Broker, queue string, bodies ...string) {
+	for _, body := range bodies {
+		require.NoError(t, b.Publish("", queue, amqp.Publishing{Body: []byte(body)}))
+	}
+}
+
+func assertBodies(t *testing.T, b *amqptest.Broker, queue string, bodies ...string) {
+	got := make([]string, 0)
+	for _, msg := range b.Messages(queue) {
+		got = append(got, string(msg.Body))
+	}
+
+	if bodies == nil {
+		bodies = []string{}
+	}
+	assert.Equal(t, bodies, got)
+}
+
+func receive(t *testing.T, msgCh <-chan amqp.Delivery) amqp.Delivery {
+	select {
+	case msg, ok := <-msgCh:
+		require.True(t, ok, "delivery channel closed")
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+		return amqp.Delivery{}
+	}
+}
+
+func waitConsumerReady(t *testing.T, stateCh <-chan consumer.State) {
+	waitState(t, func() bool { return (<-stateCh).Ready != nil })
+}
+
+func waitPublisherReady(t *testing.T, stateCh <-chan publisher.State) {
+	waitState(t, func() bool { return (<-stateCh).Ready != nil })
+}
+
+func waitState(t *testing.T, next func() bool) {
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		for !next() {
+		}
+	}()
+
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		t.Fatal("state not reached")
+	}
+}
@@ -0,0 +1,1021 @@
+package amqptest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+type unacked struct {
+	msg      message
+	queue    *queue
+	consumer *subscriber
+}
+
+type publishing struct {
+	exchange  string
+	key       string
+	mandatory bool
+	msg       amqp.Publishing
+}
+
+// event is a notification sent to the channel listeners in the order it happened.
+type event struct {
+	flow    *bool
+	confirm *amqp.Confirmation
+	ret     *amqp.Return
+	cancel  *string
+}
+
+// Channel is a channel of a Connection, it implements consumer.AMQPChannel and publisher.AMQPChannel.
+// A failed method closes the channel with an *amqp.Error, the way the broker does.
+type Channel struct {
+	conn   *Connection
+	broker *Broker
+	closed bool
+
+	consumers      map[string]*subscriber
+	unacked        map[uint64]*unacked
+	unackedCount   int
+	deliveryTag    uint64
+	prefetch       int
+	prefetchGlobal bool
+
+	confirm     bool
+	publishSeq  uint64
+	tx          bool
+	txPublishes []publishing
+
+	closes    []chan *amqp.Error
+	flows     []chan bool
+	confirms  []chan amqp.Confirmation
+	returns   []chan amqp.Return
+	cancels   []chan string
+	events    []event
+	wakeCh    chan struct{}
+	doneCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+func (ch *Channel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if name == "" {
+		return ch.exception(amqp.AccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+	}
+
+	if ex, ok := b.exchanges[name]; ok {
+		switch {
+		case ex.kind != kind:
+			return ch.inequivalent("type", "exchange", name)
+		case ex.durable != durable:
+			return ch.inequivalent("durable", "exchange", name)
+		case ex.autoDelete != autoDelete:
+			return ch.inequivalent("auto_delete", "exchange", name)
+		case ex.internal != internal:
+			return ch.inequivalent("internal", "exchange", name)
+		case !tablesEqual(ex.args, args):
+			return ch.inequivalent("arguments", "exchange", name)
+		}
+
+		return nil
+	}
+
+	if strings.HasPrefix(name, "amq.") {
+		return ch.exception(amqp.AccessRefused, fmt.Sprintf("ACCESS_REFUSED - exchange name '%s' contains reserved prefix 'amq.*'", name))
+	}
+
+	switch kind {
+	case amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeTopic, amqp.ExchangeHeaders:
+	default:
+		return ch.exception(amqp.CommandInvalid, fmt.Sprintf("COMMAND_INVALID - unknown exchange type '%s'", kind))
+	}
+
+	b.exchanges[name] = &exchange{
+		name:       name,
+		kind:       kind,
+		durable:    durable,
+		autoDelete: autoDelete,
+		internal:   internal,
+		args:       args,
+	}
+
+	return nil
+}
+
+func (ch *Channel) ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if _, ok := b.exchanges[name]; !ok {
+		return ch.notFound("exchange", name)
+	}
+
+	return nil
+}
+
+func (ch *Channel) ExchangeDelete(name string, ifUnused, noWait bool) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if name == "" || strings.HasPrefix(name, "amq.") {
+		return ch.exception(amqp.AccessRefused, fmt.Sprintf("ACCESS_REFUSED - operation not permitted on exchange '%s'", name))
+	}
+
+	ex, ok := b.exchanges[name]
+	if !ok {
+		return nil
+	}
+
+	if ifUnused && len(ex.bindings) > 0 {
+		return ch.exception(amqp.PreconditionFailed, fmt.Sprintf("PRECONDITION_FAILED - exchange '%s' in vhost '/' in use", name))
+	}
+
+	b.deleteExchange(ex)
+
+	return nil
+}
+
+func (ch *Channel) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if source == "" || destination == "" {
+		return ch.exception(amqp.AccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+	}
+
+	src, ok := b.exchanges[source]
+	if !ok {
+		return ch.notFound("exchange", source)
+	}
+	if _, ok := b.exchanges[destination]; !ok {
+		return ch.notFound("exchange", destination)
+	}
+
+	src.bind(binding{destination: destination, key: key, args: args})
+
+	return nil
+}
+
+func (ch *Channel) ExchangeUnbind(destination, key, source string, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if src, ok := b.exchanges[source]; ok {
+		unbind := binding{destination: destination, key: key, args: args}
+		b.removeBindings(src, unbind.equal)
+	}
+
+	return nil
+}
+
+func (ch *Channel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+
+	serverNamed := name == ""
+	if serverNamed {
+		name = b.newName()
+	}
+
+	if q, ok := b.queues[name]; ok {
+		if err := ch.checkOwner(q); err != nil {
+			return amqp.Queue{}, err
+		}
+
+		switch {
+		case q.durable != durable:
+			return amqp.Queue{}, ch.inequivalent("durable", "queue", name)
+		case q.autoDelete != autoDelete:
+			return amqp.Queue{}, ch.inequivalent("auto_delete", "queue", name)
+		case q.exclusive != exclusive:
+			return amqp.Queue{}, ch.inequivalent("exclusive", "queue", name)
+		case !tablesEqual(q.args, args):
+			return amqp.Queue{}, ch.inequivalent("arguments", "queue", name)
+		}
+
+		return q.state(), nil
+	}
+
+	if !serverNamed && strings.HasPrefix(name, "amq.") {
+		return amqp.Queue{}, ch.exception(amqp.AccessRefused, fmt.Sprintf("ACCESS_REFUSED - queue name '%s' contains reserved prefix 'amq.*'", name))
+	}
+
+	q := &queue{
+		name:       name,
+		durable:    durable,
+		autoDelete: autoDelete,
+		exclusive:  exclusive,
+		args:       args,
+	}
+	if exclusive {
+		q.owner = ch.conn
+	}
+	b.queues[name] = q
+
+	return q.state(), nil
+}
+
+func (ch *Channel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.Queue{}, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[name]
+	if !ok {
+		return amqp.Queue{}, ch.notFound("queue", name)
+	}
+	if err := ch.checkOwner(q); err != nil {
+		return amqp.Queue{}, err
+	}
+
+	return q.state(), nil
+}
+
+func (ch *Channel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if exchange == "" {
+		return ch.exception(amqp.AccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+	}
+
+	q, ok := b.queues[name]
+	if !ok {
+		return ch.notFound("queue", name)
+	}
+	if err := ch.checkOwner(q); err != nil {
+		return err
+	}
+
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		return ch.notFound("exchange", exchange)
+	}
+
+	ex.bind(binding{destination: name, queue: true, key: key, args: args})
+
+	return nil
+}
+
+func (ch *Channel) QueueUnbind(name, key, exchange string, args amqp.Table) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if exchange == "" {
+		return ch.exception(amqp.AccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+	}
+
+	if ex, ok := b.exchanges[exchange]; ok {
+		unbind := binding{destination: name, queue: true, key: key, args: args}
+		b.removeBindings(ex, unbind.equal)
+	}
+
+	return nil
+}
+
+func (ch *Channel) QueuePurge(name string, noWait bool) (int, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return 0, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[name]
+	if !ok {
+		return 0, ch.notFound("queue", name)
+	}
+	if err := ch.checkOwner(q); err != nil {
+		return 0, err
+	}
+
+	purged := len(q.messages)
+	q.messages = nil
+
+	return purged, nil
+}
+
+func (ch *Channel) QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return 0, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[name]
+	if !ok {
+		return 0, nil
+	}
+	if err := ch.checkOwner(q); err != nil {
+		return 0, err
+	}
+
+	if ifUnused && len(q.consumers) > 0 {
+		return 0, ch.exception(amqp.PreconditionFailed, fmt.Sprintf("PRECONDITION_FAILED - queue '%s' in vhost '/' in use", name))
+	}
+	if ifEmpty && len(q.messages) > 0 {
+		return 0, ch.exception(amqp.PreconditionFailed, fmt.Sprintf("PRECONDITION_FAILED - queue '%s' in vhost '/' not empty", name))
+	}
+
+	deleted := len(q.messages)
+	b.deleteQueue(q)
+
+	return deleted, nil
+}
+
+// Qos limits the number of unacked messages per consumer, or per channel if global is set.
+// The prefetch size is ignored.
+func (ch *Channel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	ch.prefetch = prefetchCount
+	ch.prefetchGlobal = global
+
+	return nil
+}
+
+func (ch *Channel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil, ch.notFound("queue", queue)
+	}
+	if err := ch.checkOwner(q); err != nil {
+		return nil, err
+	}
+
+	if consumer == "" {
+		consumer = b.newConsumerTag()
+	}
+	if _, ok := ch.consumers[consumer]; ok {
+		return nil, ch.exception(amqp.NotAllowed, fmt.Sprintf("NOT_ALLOWED - attempt to reuse consumer tag '%s'", consumer))
+	}
+
+	for _, other := range q.consumers {
+		if exclusive || other.exclusive {
+			return nil, ch.exception(amqp.AccessRefused, fmt.Sprintf("ACCESS_REFUSED - queue '%s' in vhost '/' in exclusive use", queue))
+		}
+	}
+
+	prefetch := ch.prefetch
+	if ch.prefetchGlobal {
+		prefetch = 0
+	}
+
+	c := newSubscriber(ch, q, consumer, autoAck, exclusive, prefetch)
+	ch.consumers[consumer] = c
+	q.consumers = append(q.consumers, c)
+	q.hadConsumers = true
+	q.dispatch()
+
+	return c.deliveryCh, nil
+}
+
+// Cancel stops the consumer and closes its delivery channel.
+// The messages the client has not received yet are requeued, the received ones wait for an ack.
+func (ch *Channel) Cancel(consumer string, noWait bool) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	c, ok := ch.consumers[consumer]
+	if !ok {
+		return nil
+	}
+
+	ch.cancelConsumer(c)
+
+	return nil
+}
+
+// Publish routes the message.
+// As with the broker, a failure closes the channel and is not returned, subscribe with NotifyClose to know about it.
+func (ch *Channel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if immediate {
+		ch.exception(amqp.NotImplemented, "NOT_IMPLEMENTED - immediate=true")
+		return nil
+	}
+
+	ex, ok := b.exchanges[exchange]
+	if !ok {
+		ch.notFound("exchange", exchange)
+		return nil
+	}
+	if ex.internal {
+		ch.exception(amqp.AccessRefused, fmt.Sprintf("ACCESS_REFUSED - cannot publish to internal exchange '%s' in vhost '/'", exchange))
+		return nil
+	}
+
+	p := publishing{exchange: exchange, key: key, mandatory: mandatory, msg: msg}
+	if ch.tx {
+		ch.txPublishes = append(ch.txPublishes, p)
+		return nil
+	}
+
+	return ch.publish(p)
+}
+
+// Confirm puts the channel into confirm mode, every publish is acked once it is routed.
+func (ch *Channel) Confirm(noWait bool) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if ch.tx {
+		return ch.exception(amqp.PreconditionFailed, "PRECONDITION_FAILED - cannot switch from tx to confirm mode")
+	}
+
+	ch.confirm = true
+
+	return nil
+}
+
+// Tx puts the channel into transactional mode, publishes are routed on TxCommit.
+// Acks and nacks are not part of the transaction, they are applied right away.
+func (ch *Channel) Tx() error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if ch.confirm {
+		return ch.exception(amqp.PreconditionFailed, "PRECONDITION_FAILED - cannot switch from confirm to tx mode")
+	}
+
+	ch.tx = true
+
+	return nil
+}
+
+func (ch *Channel) TxCommit() error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if !ch.tx {
+		return ch.exception(amqp.PreconditionFailed, "PRECONDITION_FAILED - channel is not transactional")
+	}
+
+	publishes := ch.txPublishes
+	ch.txPublishes = nil
+	for _, p := range publishes {
+		if err := ch.publish(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ch *Channel) TxRollback() error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	if !ch.tx {
+		return ch.exception(amqp.PreconditionFailed, "PRECONDITION_FAILED - channel is not transactional")
+	}
+
+	ch.txPublishes = nil
+
+	return nil
+}
+
+// Ack implements amqp.Acknowledger.
+func (ch *Channel) Ack(tag uint64, multiple bool) error {
+	return ch.settle(tag, multiple, func(u *unacked) {})
+}
+
+// Nack implements amqp.Acknowledger.
+func (ch *Channel) Nack(tag uint64, multiple, requeue bool) error {
+	return ch.settle(tag, multiple, func(u *unacked) {
+		if requeue {
+			u.queue.requeue(u.msg)
+		}
+	})
+}
+
+// Reject implements amqp.Acknowledger.
+func (ch *Channel) Reject(tag uint64, requeue bool) error {
+	return ch.Nack(tag, false, requeue)
+}
+
+// NotifyClose registers a listener for the channel close.
+// The error is sent if the broker closed the channel, the listener is closed in any case.
+func (ch *Channel) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		close(c)
+		return c
+	}
+
+	ch.closes = append(ch.closes, c)
+
+	return c
+}
+
+// NotifyFlow registers a listener for the flow control set by Broker.SetFlow.
+func (ch *Channel) NotifyFlow(c chan bool) chan bool {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		close(c)
+		return c
+	}
+
+	ch.flows = append(ch.flows, c)
+
+	return c
+}
+
+// NotifyPublish registers a listener for the publish confirmations.
+func (ch *Channel) NotifyPublish(c chan amqp.Confirmation) chan amqp.Confirmation {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		close(c)
+		return c
+	}
+
+	ch.confirms = append(ch.confirms, c)
+
+	return c
+}
+
+// NotifyReturn registers a listener for the mandatory messages which could not be routed.
+func (ch *Channel) NotifyReturn(c chan amqp.Return) chan amqp.Return {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		close(c)
+		return c
+	}
+
+	ch.returns = append(ch.returns, c)
+
+	return c
+}
+
+// NotifyCancel registers a listener for the consumers canceled by the broker, for example when their queue is deleted.
+func (ch *Channel) NotifyCancel(c chan string) chan string {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		close(c)
+		return c
+	}
+
+	ch.cancels = append(ch.cancels, c)
+
+	return c
+}
+
+// Close closes the channel, the messages awaiting an ack are requeued.
+func (ch *Channel) Close() error {
+	b := ch.broker
+	b.mu.Lock()
+
+	if ch.closed {
+		b.mu.Unlock()
+		return amqp.ErrClosed
+	}
+
+	ch.closeLocked()
+	b.mu.Unlock()
+
+	ch.finish(nil)
+
+	return nil
+}
+
+func (ch *Channel) publish(p publishing) error {
+	b := ch.broker
+
+	ex, ok := b.exchanges[p.exchange]
+	if !ok {
+		return ch.notFound("exchange", p.exchange)
+	}
+
+	queues := b.route(ex, p.key, p.msg.Headers)
+	if len(queues) == 0 && p.mandatory {
+		ch.notify(event{ret: &amqp.Return{
+			ReplyCode:       amqp.NoRoute,
+			ReplyText:       "NO_ROUTE",
+			Exchange:        p.exchange,
+			RoutingKey:      p.key,
+			ContentType:     p.msg.ContentType,
+			ContentEncoding: p.msg.ContentEncoding,
+			Headers:         p.msg.Headers,
+			DeliveryMode:    p.msg.DeliveryMode,
+			Priority:        p.msg.Priority,
+			CorrelationId:   p.msg.CorrelationId,
+			ReplyTo:         p.msg.ReplyTo,
+			Expiration:      p.msg.Expiration,
+			MessageId:       p.msg.MessageId,
+			Timestamp:       p.msg.Timestamp,
+			Type:            p.msg.Type,
+			UserId:          p.msg.UserId,
+			AppId:           p.msg.AppId,
+			Body:            p.msg.Body,
+		}})
+	}
+
+	b.deliver(queues, p.exchange, p.key, p.msg)
+
+	if ch.confirm {
+		ch.publishSeq++
+		ch.notify(event{confirm: &amqp.Confirmation{DeliveryTag: ch.publishSeq, Ack: true}})
+	}
+
+	return nil
+}
+
+func (ch *Channel) ready() bool {
+	return !ch.prefetchGlobal || ch.prefetch == 0 || ch.unackedCount < ch.prefetch
+}
+
+func (ch *Channel) deliver(c *subscriber, q *queue, m message) {
+	ch.deliveryTag++
+
+	if !c.autoAck {
+		ch.unacked[ch.deliveryTag] = &unacked{msg: m, queue: q, consumer: c}
+		ch.unackedCount++
+		c.unacked++
+	}
+
+	c.push(amqp.Delivery{
+		Acknowledger:    ch,
+		Headers:         m.msg.Headers,
+		ContentType:     m.msg.ContentType,
+		ContentEncoding: m.msg.ContentEncoding,
+		DeliveryMode:    m.msg.DeliveryMode,
+		Priority:        m.msg.Priority,
+		CorrelationId:   m.msg.CorrelationId,
+		ReplyTo:         m.msg.ReplyTo,
+		Expiration:      m.msg.Expiration,
+		MessageId:       m.msg.MessageId,
+		Timestamp:       m.msg.Timestamp,
+		Type:            m.msg.Type,
+		UserId:          m.msg.UserId,
+		AppId:           m.msg.AppId,
+		ConsumerTag:     c.tag,
+		DeliveryTag:     ch.deliveryTag,
+		Redelivered:     m.redelivered,
+		Exchange:        m.exchange,
+		RoutingKey:      m.key,
+		Body:            m.msg.Body,
+	})
+}
+
+// settle removes the unacked messages up to the tag if multiple is set, or the message with the tag.
+// The messages are passed to fn from the latest, so requeued ones keep their order.
+func (ch *Channel) settle(tag uint64, multiple bool, fn func(u *unacked)) error {
+	b := ch.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+
+	var tags []uint64
+	if multiple {
+		for t := range ch.unacked {
+			if tag == 0 || t <= tag {
+				tags = append(tags, t)
+			}
+		}
+	} else {
+		if _, ok := ch.unacked[tag]; !ok {
+			return ch.exception(amqp.PreconditionFailed, fmt.Sprintf("PRECONDITION_FAILED - unknown delivery tag %d", tag))
+		}
+		tags = append(tags, tag)
+	}
+
+	ch.settleTags(tags, fn)
+
+	return nil
+}
+
+func (ch *Channel) settleTags(tags []uint64, fn func(u *unacked)) {
+	sort.Slice(tags, func(i, j int) bool { return tags[i] > tags[j] })
+
+	queues := make(map[*queue]struct{})
+	for _, t := range tags {
+		u, ok := ch.unacked[t]
+		if !ok {
+			continue
+		}
+
+		delete(ch.unacked, t)
+		ch.unackedCount--
+		u.consumer.unacked--
+
+		fn(u)
+		queues[u.queue] = struct{}{}
+	}
+
+	for q := range queues {
+		if !q.deleted {
+			q.dispatch()
+		}
+	}
+
+	// a channel wide prefetch could hold back messages of other queues.
+	if ch.prefetchGlobal {
+		for _, c := range ch.consumers {
+			c.queue.dispatch()
+		}
+	}
+}
+
+func (ch *Channel) removeConsumer(c *subscriber) {
+	delete(ch.consumers, c.tag)
+}
+
+// cancelConsumer stops the consumer and requeues the messages the client has not received.
+func (ch *Channel) cancelConsumer(c *subscriber) {
+	b := ch.broker
+	q := c.queue
+
+	ch.removeConsumer(c)
+	q.removeConsumer(c)
+
+	var tags []uint64
+	for _, d := range c.stop() {
+		if !c.autoAck {
+			tags = append(tags, d.DeliveryTag)
+		}
+	}
+	ch.settleTags(tags, func(u *unacked) {
+		u.queue.requeue(u.msg)
+	})
+
+	if q.autoDelete && q.hadConsumers && len(q.consumers) == 0 && !q.deleted {
+		b.deleteQueue(q)
+	}
+}
+
+func (ch *Channel) checkOwner(q *queue) error {
+	if q.owner != nil && q.owner != ch.conn {
+		return ch.exception(amqp.ResourceLocked, fmt.Sprintf("RESOURCE_LOCKED - cannot obtain exclusive access to locked queue '%s' in vhost '/'", q.name))
+	}
+
+	return nil
+}
+
+func (ch *Channel) notFound(kind, name string) error {
+	err := notFound(kind, name)
+	ch.shutdown(err)
+
+	return err
+}
+
+func (ch *Channel) inequivalent(arg, kind, name string) error {
+	return ch.exception(amqp.PreconditionFailed, fmt.Sprintf("PRECONDITION_FAILED - inequivalent arg '%s' for %s '%s' in vhost '/'", arg, kind, name))
+}
+
+func (ch *Channel) exception(code int, reason string) error {
+	err := &amqp.Error{Code: code, Reason: reason, Server: true}
+	ch.shutdown(err)
+
+	return err
+}
+
+func (ch *Channel) notify(e event) {
+	ch.events = append(ch.events, e)
+
+	select {
+	case ch.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchEvents sends the events to the listeners one by one, so a slow listener does not block the broker.
+func (ch *Channel) dispatchEvents() {
+	defer close(ch.stoppedCh)
+
+	b := ch.broker
+	for {
+		b.mu.Lock()
+		if len(ch.events) == 0 {
+			b.mu.Unlock()
+
+			select {
+			case <-ch.wakeCh:
+				continue
+			case <-ch.doneCh:
+				return
+			}
+		}
+
+		e := ch.events[0]
+		ch.events = ch.events[1:]
+		flows := ch.flows
+		confirms := ch.confirms
+		returns := ch.returns
+		cancels := ch.cancels
+		b.mu.Unlock()
+
+		switch {
+		case e.flow != nil:
+			for _, l := range flows {
+				select {
+				case l <- *e.flow:
+				case <-ch.doneCh:
+					return
+				}
+			}
+		case e.confirm != nil:
+			for _, l := range confirms {
+				select {
+				case l <- *e.confirm:
+				case <-ch.doneCh:
+					return
+				}
+			}
+		case e.ret != nil:
+			for _, l := range returns {
+				select {
+				case l <- *e.ret:
+				case <-ch.doneCh:
+					return
+				}
+			}
+		case e.cancel != nil:
+			for _, l := range cancels {
+				select {
+				case l <- *e.cancel:
+				case <-ch.doneCh:
+					return
+				}
+			}
+		}
+	}
+}
+
+// shutdown closes the channel on behalf of the broker.
+func (ch *Channel) shutdown(err *amqp.Error) {
+	if ch.closed {
+		return
+	}
+
+	ch.closeLocked()
+	go ch.finish(err)
+}
+
+// closeLocked cancels the consumers and requeues the messages awaiting an ack.
+func (ch *Channel) closeLocked() {
+	ch.closed = true
+	delete(ch.conn.channels, ch)
+
+	for _, c := range ch.consumers {
+		ch.cancelConsumer(c)
+	}
+
+	tags := make([]uint64, 0, len(ch.unacked))
+	for t := range ch.unacked {
+		tags = append(tags, t)
+	}
+	ch.settleTags(tags, func(u *unacked) {
+		u.queue.requeue(u.msg)
+	})
+
+	close(ch.doneCh)
+}
+
+// finish waits for the event dispatching to stop, sends the error to the close listeners and closes all the listeners.
+func (ch *Channel) finish(err *amqp.Error) {
+	<-ch.stoppedCh
+
+	b := ch.broker
+	b.mu.Lock()
+	closes := ch.closes
+	flows := ch.flows
+	confirms := ch.confirms
+	returns := ch.returns
+	cancels := ch.cancels
+	ch.closes, ch.flows, ch.confirms, ch.returns, ch.cancels = nil, nil, nil, nil, nil
+	b.mu.Unlock()
+
+	for _, l := range closes {
+		if err != nil {
+			l <- err
+		}
+		close(l)
+	}
+	for _, l := range flows {
+		close(l)
+	}
+	for _, l := range confirms {
+		close(l)
+	}
+	for _, l := range returns {
+		close(l)
+	}
+	for _, l := range cancels {
+		close(l)
+	}
+}
@@ -0,0 +1,108 @@
+package amqptest
+
+import (
+	"github.com/streadway/amqp"
+)
+
+// Connection is a connection to the Broker, it implements amqpextra.AMQPConnection.
+type Connection struct {
+	broker   *Broker
+	channels map[*Channel]struct{}
+	closes   []chan *amqp.Error
+	closed   bool
+}
+
+// Channel opens a channel, it implements consumer.AMQPChannel and publisher.AMQPChannel.
+func (c *Connection) Channel() (*Channel, error) {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	ch := &Channel{
+		conn:      c,
+		broker:    b,
+		consumers: make(map[string]*subscriber),
+		unacked:   make(map[uint64]*unacked),
+		wakeCh:    make(chan struct{}, 1),
+		doneCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}
+	c.channels[ch] = struct{}{}
+
+	go ch.dispatchEvents()
+
+	return ch, nil
+}
+
+// NotifyClose registers a listener for the connection close.
+// The error is sent if the broker closed the connection, the listener is closed in any case.
+func (c *Connection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c.closed {
+		close(receiver)
+		return receiver
+	}
+
+	c.closes = append(c.closes, receiver)
+
+	return receiver
+}
+
+// Close closes the connection and its channels, the exclusive queues of the connection are deleted.
+func (c *Connection) Close() error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c.closed {
+		return amqp.ErrClosed
+	}
+
+	c.shutdown(nil)
+
+	return nil
+}
+
+// shutdown closes the connection and its channels.
+// Like streadway, the connection listeners are notified before the channel ones.
+func (c *Connection) shutdown(err *amqp.Error) {
+	b := c.broker
+
+	c.closed = true
+	delete(b.conns, c)
+
+	channels := make([]*Channel, 0, len(c.channels))
+	for ch := range c.channels {
+		ch.closeLocked()
+		channels = append(channels, ch)
+	}
+
+	for _, q := range b.queues {
+		if q.owner == c {
+			b.deleteQueue(q)
+		}
+	}
+
+	closes := c.closes
+	c.closes = nil
+
+	go func() {
+		for _, l := range closes {
+			if err != nil {
+				l <- err
+			}
+			close(l)
+		}
+
+		for _, ch := range channels {
+			ch.finish(err)
+		}
+	}()
+}
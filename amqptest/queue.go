@@ -0,0 +1,189 @@
+package amqptest
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+type message struct {
+	exchange    string
+	key         string
+	msg         amqp.Publishing
+	redelivered bool
+}
+
+type queue struct {
+	name       string
+	durable    bool
+	autoDelete bool
+	exclusive  bool
+	args       amqp.Table
+	owner      *Connection
+
+	messages     []message
+	consumers    []*subscriber
+	next         int
+	hadConsumers bool
+	deleted      bool
+}
+
+func (q *queue) state() amqp.Queue {
+	return amqp.Queue{
+		Name:      q.name,
+		Messages:  len(q.messages),
+		Consumers: len(q.consumers),
+	}
+}
+
+func (q *queue) requeue(m message) {
+	if q.deleted {
+		return
+	}
+
+	m.redelivered = true
+	q.messages = append([]message{m}, q.messages...)
+}
+
+// dispatch hands out ready messages to the consumers in turn while they have prefetch capacity.
+func (q *queue) dispatch() {
+	for len(q.messages) > 0 {
+		c := q.nextConsumer()
+		if c == nil {
+			return
+		}
+
+		m := q.messages[0]
+		q.messages = q.messages[1:]
+		c.ch.deliver(c, q, m)
+	}
+}
+
+func (q *queue) nextConsumer() *subscriber {
+	for i := 0; i < len(q.consumers); i++ {
+		c := q.consumers[(q.next+i)%len(q.consumers)]
+		if c.ready() {
+			q.next = (q.next + i + 1) % len(q.consumers)
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (q *queue) removeConsumer(c *subscriber) {
+	for i, other := range q.consumers {
+		if other == c {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			q.next = 0
+			return
+		}
+	}
+}
+
+// subscriber buffers deliveries so the broker never blocks on a slow client, as streadway's consumer does.
+type subscriber struct {
+	tag       string
+	ch        *Channel
+	queue     *queue
+	autoAck   bool
+	exclusive bool
+	prefetch  int
+	unacked   int
+
+	deliveryCh chan amqp.Delivery
+
+	mu        sync.Mutex
+	buf       []amqp.Delivery
+	wakeCh    chan struct{}
+	doneCh    chan struct{}
+	stoppedCh chan struct{}
+	stopOnce  sync.Once
+}
+
+func newSubscriber(ch *Channel, q *queue, tag string, autoAck, exclusive bool, prefetch int) *subscriber {
+	c := &subscriber{
+		tag:        tag,
+		ch:         ch,
+		queue:      q,
+		autoAck:    autoAck,
+		exclusive:  exclusive,
+		prefetch:   prefetch,
+		deliveryCh: make(chan amqp.Delivery),
+		wakeCh:     make(chan struct{}, 1),
+		doneCh:     make(chan struct{}),
+		stoppedCh:  make(chan struct{}),
+	}
+
+	go c.run()
+
+	return c
+}
+
+func (c *subscriber) ready() bool {
+	if c.autoAck {
+		return true
+	}
+	if c.prefetch > 0 && c.unacked >= c.prefetch {
+		return false
+	}
+
+	return c.ch.ready()
+}
+
+func (c *subscriber) push(d amqp.Delivery) {
+	c.mu.Lock()
+	c.buf = append(c.buf, d)
+	c.mu.Unlock()
+
+	select {
+	case c.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *subscriber) run() {
+	defer close(c.stoppedCh)
+	defer close(c.deliveryCh)
+
+	for {
+		c.mu.Lock()
+		if len(c.buf) == 0 {
+			c.mu.Unlock()
+
+			select {
+			case <-c.wakeCh:
+				continue
+			case <-c.doneCh:
+				return
+			}
+		}
+		d := c.buf[0]
+		c.mu.Unlock()
+
+		select {
+		case c.deliveryCh <- d:
+			c.mu.Lock()
+			c.buf = c.buf[1:]
+			c.mu.Unlock()
+		case <-c.doneCh:
+			return
+		}
+	}
+}
+
+// stop closes the delivery channel and returns the deliveries the client has not received.
+func (c *subscriber) stop() []amqp.Delivery {
+	c.stopOnce.Do(func() {
+		close(c.doneCh)
+	})
+	<-c.stoppedCh
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf := c.buf
+	c.buf = nil
+
+	return buf
+}
@@ -0,0 +1,207 @@
+package amqptest
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/streadway/amqp"
+)
+
+type exchange struct {
+	name       string
+	kind       string
+	durable    bool
+	autoDelete bool
+	internal   bool
+	args       amqp.Table
+	bindings   []binding
+}
+
+// binding routes messages of the exchange to a queue or another exchange.
+type binding struct {
+	destination string
+	queue       bool
+	key         string
+	args        amqp.Table
+}
+
+func (bd binding) equal(other binding) bool {
+	return bd.destination == other.destination &&
+		bd.queue == other.queue &&
+		bd.key == other.key &&
+		tablesEqual(bd.args, other.args)
+}
+
+func (ex *exchange) bind(bd binding) {
+	for _, existing := range ex.bindings {
+		if existing.equal(bd) {
+			return
+		}
+	}
+
+	ex.bindings = append(ex.bindings, bd)
+}
+
+// route returns the queues the message is routed to by the exchange and the exchanges bound to it.
+// A queue is returned once even if several bindings match.
+func (b *Broker) route(ex *exchange, key string, headers amqp.Table) []*queue {
+	var queues []*queue
+	seenQueues := make(map[string]bool)
+	seenExchanges := make(map[string]bool)
+
+	var walk func(ex *exchange)
+	walk = func(ex *exchange) {
+		if seenExchanges[ex.name] {
+			return
+		}
+		seenExchanges[ex.name] = true
+
+		if ex.name == "" {
+			if q, ok := b.queues[key]; ok && !seenQueues[key] {
+				seenQueues[key] = true
+				queues = append(queues, q)
+			}
+
+			return
+		}
+
+		for _, bd := range ex.bindings {
+			if !matches(ex.kind, bd, key, headers) {
+				continue
+			}
+
+			if !bd.queue {
+				if dest, ok := b.exchanges[bd.destination]; ok {
+					walk(dest)
+				}
+
+				continue
+			}
+
+			if q, ok := b.queues[bd.destination]; ok && !seenQueues[q.name] {
+				seenQueues[q.name] = true
+				queues = append(queues, q)
+			}
+		}
+	}
+	walk(ex)
+
+	return queues
+}
+
+func matches(kind string, bd binding, key string, headers amqp.Table) bool {
+	switch kind {
+	case amqp.ExchangeDirect:
+		return bd.key == key
+	case amqp.ExchangeFanout:
+		return true
+	case amqp.ExchangeTopic:
+		return topicMatches(strings.Split(bd.key, "."), strings.Split(key, "."))
+	case amqp.ExchangeHeaders:
+		return headersMatch(bd.args, headers)
+	}
+
+	return false
+}
+
+// topicMatches matches the routing key words against the binding pattern words,
+// * stands for exactly one word and # for zero or more words.
+func topicMatches(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatches(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(words) > 0 && topicMatches(pattern[1:], words[1:])
+	}
+
+	return len(words) > 0 && pattern[0] == words[0] && topicMatches(pattern[1:], words[1:])
+}
+
+// headersMatch matches the message headers against the binding arguments.
+// x-match any requires one of the arguments to match, all (the default) requires every argument.
+// Arguments starting with x- are not matched.
+func headersMatch(args, headers amqp.Table) bool {
+	matchAny := args["x-match"] == "any"
+
+	matched := 0
+	total := 0
+	for k, v := range args {
+		if strings.HasPrefix(k, "x-") {
+			continue
+		}
+		total++
+
+		hv, ok := headers[k]
+		if !ok || !valuesEqual(v, hv) {
+			continue
+		}
+		if matchAny {
+			return true
+		}
+		matched++
+	}
+
+	if matchAny {
+		return false
+	}
+
+	return matched == total
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if ai, ok := toInt64(a); ok {
+		bi, ok := toInt64(b)
+		return ok && ai == bi
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	}
+
+	return 0, false
+}
+
+// tablesEqual compares arguments the way the broker checks declarations are equivalent, nil and empty tables are equal.
+func tablesEqual(a, b amqp.Table) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, av := range a {
+		bv, ok := b[k]
+		if !ok || !valuesEqual(av, bv) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,172 @@
+// Package backoff provides reconnect delay strategies shared by Dialer, Consumer and Publisher.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff tells how long to wait before the next reconnect attempt.
+type Backoff interface {
+	// Next returns a delay before the next attempt.
+	Next() time.Duration
+	// Reset starts the sequence over. It is called once a connection has been stable.
+	Reset()
+}
+
+// Cloner is implemented by a Backoff keeping state between attempts.
+// A pool of connections or channels clones it, so each of them backs off on its own.
+type Cloner interface {
+	// Clone returns a Backoff with the same settings and a fresh sequence.
+	Clone() Backoff
+}
+
+// Clone returns a copy of b with its own state if b implements Cloner, otherwise b itself.
+func Clone(b Backoff) Backoff {
+	if c, ok := b.(Cloner); ok {
+		return c.Clone()
+	}
+
+	return b
+}
+
+type constant time.Duration
+
+// Constant returns Backoff that always waits the same duration.
+func Constant(dur time.Duration) Backoff {
+	return constant(dur)
+}
+
+func (c constant) Next() time.Duration {
+	return time.Duration(c)
+}
+
+func (c constant) Reset() {}
+
+// Exponential multiplies the delay by Factor on every attempt until Max is reached.
+// Jitter randomizes the delay so that clients disconnected at the same moment do not reconnect at the same moment.
+type Exponential struct {
+	// Min is a delay before the first attempt.
+	Min time.Duration
+	// Max caps the delay.
+	Max time.Duration
+	// Factor the delay is multiplied by on each attempt. Default: 2.
+	Factor float64
+	// Jitter is a fraction of the delay which is randomized, from 0 (no jitter) to 1 (full jitter).
+	Jitter float64
+
+	mu      sync.Mutex
+	attempt int
+	rnd     *rand.Rand
+}
+
+// NewExponential returns Exponential backoff with factor 2 and half of the delay randomized.
+func NewExponential(min, max time.Duration) *Exponential {
+	if min <= 0 {
+		panic("min must be greater than zero")
+	}
+	if max < min {
+		panic("max must be greater or equal to min")
+	}
+
+	return &Exponential{
+		Min:    min,
+		Max:    max,
+		Factor: 2,
+		Jitter: 0.5,
+	}
+}
+
+func (e *Exponential) Next() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	factor := e.Factor
+	if factor < 1 {
+		factor = 2
+	}
+
+	d := float64(e.Min) * math.Pow(factor, float64(e.attempt))
+	if d >= float64(e.Max) || math.IsInf(d, 0) {
+		d = float64(e.Max)
+	} else {
+		e.attempt++
+	}
+
+	if e.Jitter > 0 {
+		if e.rnd == nil {
+			e.rnd = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // jitter does not need crypto rand
+		}
+
+		d -= d * math.Min(e.Jitter, 1) * e.rnd.Float64()
+	}
+
+	return time.Duration(d)
+}
+
+// Clone returns Exponential with the same settings and the sequence started over.
+func (e *Exponential) Clone() Backoff {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return &Exponential{
+		Min:    e.Min,
+		Max:    e.Max,
+		Factor: e.Factor,
+		Jitter: e.Jitter,
+	}
+}
+
+func (e *Exponential) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.attempt = 0
+}
+
+// Retry is used by state machines to wait between reconnect attempts.
+// It resets Backoff once a connection stayed ready for the stable period.
+// Retry is not safe for concurrent use, it's expected to be owned by a single state machine goroutine.
+type Retry struct {
+	backoff      Backoff
+	stablePeriod time.Duration
+	readyAt      time.Time
+}
+
+// NewRetry returns Retry for the given Backoff.
+func NewRetry(b Backoff, stablePeriod time.Duration) *Retry {
+	return &Retry{
+		backoff:      b,
+		stablePeriod: stablePeriod,
+	}
+}
+
+// Ready must be called once the connection is established.
+func (r *Retry) Ready() {
+	r.readyAt = time.Now()
+}
+
+// Timer returns a timer which fires once the next attempt could be made.
+// The timer must be released with Stop.
+func (r *Retry) Timer() *time.Timer {
+	if !r.readyAt.IsZero() {
+		if time.Since(r.readyAt) >= r.stablePeriod {
+			r.backoff.Reset()
+		}
+
+		r.readyAt = time.Time{}
+	}
+
+	return time.NewTimer(r.backoff.Next())
+}
+
+// Stop stops the timer and drains its channel.
+func Stop(timer *time.Timer) {
+	timer.Stop()
+	select {
+	case <-timer.C:
+	default:
+	}
+}
@@ -0,0 +1,123 @@
+package backoff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makasim/amqpextra/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstant(t *testing.T) {
+	b := backoff.Constant(time.Second)
+
+	require.Equal(t, time.Second, b.Next())
+	require.Equal(t, time.Second, b.Next())
+
+	b.Reset()
+	require.Equal(t, time.Second, b.Next())
+
+	require.Equal(t, b, backoff.Clone(b))
+}
+
+func TestExponential(main *testing.T) {
+	main.Run("PanicIfMinNotPositive", func(t *testing.T) {
+		require.PanicsWithValue(t, "min must be greater than zero", func() {
+			backoff.NewExponential(0, time.Second)
+		})
+	})
+
+	main.Run("PanicIfMaxLessThanMin", func(t *testing.T) {
+		require.PanicsWithValue(t, "max must be greater or equal to min", func() {
+			backoff.NewExponential(time.Second, time.Millisecond)
+		})
+	})
+
+	main.Run("GrowsUntilMax", func(t *testing.T) {
+		b := backoff.NewExponential(time.Millisecond*100, time.Second)
+		b.Jitter = 0
+
+		assert.Equal(t, time.Millisecond*100, b.Next())
+		assert.Equal(t, time.Millisecond*200, b.Next())
+		assert.Equal(t, time.Millisecond*400, b.Next())
+		assert.Equal(t, time.Millisecond*800, b.Next())
+		assert.Equal(t, time.Second, b.Next())
+		assert.Equal(t, time.Second, b.Next())
+	})
+
+	main.Run("Reset", func(t *testing.T) {
+		b := backoff.NewExponential(time.Millisecond*100, time.Second)
+		b.Jitter = 0
+
+		b.Next()
+		b.Next()
+		b.Reset()
+
+		assert.Equal(t, time.Millisecond*100, b.Next())
+	})
+
+	main.Run("Clone", func(t *testing.T) {
+		b := backoff.NewExponential(time.Millisecond*100, time.Second)
+		b.Jitter = 0
+
+		b.Next()
+		b.Next()
+
+		clone := backoff.Clone(b)
+		assert.Equal(t, time.Millisecond*100, clone.Next())
+		assert.Equal(t, time.Millisecond*200, clone.Next())
+
+		assert.Equal(t, time.Millisecond*400, b.Next())
+	})
+
+	main.Run("Jitter", func(t *testing.T) {
+		b := backoff.NewExponential(time.Second, time.Second)
+		b.Jitter = 0.5
+
+		for i := 0; i < 100; i++ {
+			d := b.Next()
+			require.True(t, d > time.Millisecond*500 && d <= time.Second, "got %s", d)
+		}
+	})
+}
+
+func TestRetry(main *testing.T) {
+	main.Run("NoResetIfNotStable", func(t *testing.T) {
+		b := backoff.NewExponential(time.Millisecond, time.Second)
+		b.Jitter = 0
+		r := backoff.NewRetry(b, time.Hour)
+
+		backoff.Stop(r.Timer())
+		r.Ready()
+		backoff.Stop(r.Timer())
+
+		assert.Equal(t, time.Millisecond*4, b.Next())
+	})
+
+	main.Run("ResetIfStable", func(t *testing.T) {
+		b := backoff.NewExponential(time.Millisecond, time.Second)
+		b.Jitter = 0
+		r := backoff.NewRetry(b, time.Millisecond*10)
+
+		backoff.Stop(r.Timer())
+		r.Ready()
+		time.Sleep(time.Millisecond * 20)
+		backoff.Stop(r.Timer())
+
+		assert.Equal(t, time.Millisecond*2, b.Next())
+	})
+
+	main.Run("TimerFires", func(t *testing.T) {
+		r := backoff.NewRetry(backoff.Constant(time.Millisecond*10), 0)
+
+		timer := r.Timer()
+		defer backoff.Stop(timer)
+
+		select {
+		case <-timer.C:
+		case <-time.NewTimer(time.Second).C:
+			t.Fatal("timer must fire")
+		}
+	})
+}
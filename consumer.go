@@ -37,7 +37,7 @@ func proxyConsumerConn(
 					return
 				}
 
-				consumerConn := consumer.NewConnection(conn.AMQPConnection(), conn.NotifyLost())
+				consumerConn := consumer.NewConnection(conn.amqpConn, conn.NotifyLost())
 
 				select {
 				case consumerConnCh <- consumerConn:
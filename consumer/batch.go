@@ -0,0 +1,148 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/makasim/amqpextra/logger"
+	"github.com/streadway/amqp"
+)
+
+// BatchHandler handles several messages at once.
+// It returns either one result that settles the whole batch, or a result per message in the same order.
+// A handler which settles the messages itself returns []Result{Settled}.
+// Any other number of results is a handler bug, the worker logs an error and requeues the messages.
+type BatchHandler interface {
+	HandleBatch(ctx context.Context, msgs []amqp.Delivery) []Result
+}
+
+type BatchHandlerFunc func(ctx context.Context, msgs []amqp.Delivery) []Result
+
+func (f BatchHandlerFunc) HandleBatch(ctx context.Context, msgs []amqp.Delivery) []Result {
+	return f(ctx, msgs)
+}
+
+// BatchWorker collects up to Size messages, or as many as came within Timeout since the first one,
+// and passes them to Handler at once. The Handler passed to Serve is not used.
+// The consumer prefetch count set by WithQos must be not less than Size, otherwise a batch never fills up.
+// A partial batch is flushed when the deliveries are over, like after Consumer.Shutdown canceled the consumption.
+// It is dropped when ctx is done, which also happens when the channel is lost,
+// the messages are left unsettled and the server delivers them again.
+//
+// One result for the whole batch is settled with multiple flag set, if it is Ack, Nack or Requeue.
+// The flag settles all unsettled messages of the channel, so do not return it while a RetryAfter of a previous batch is still waiting.
+type BatchWorker struct {
+	Handler BatchHandler
+	Size    int
+	Timeout time.Duration
+	Logger  logger.Logger
+}
+
+func NewBatchWorker(h BatchHandler, size int, timeout time.Duration) *BatchWorker {
+	if h == nil {
+		panic("batch handler must be not nil")
+	}
+	if size < 1 {
+		panic("batch size must be greater than zero")
+	}
+	if timeout <= 0 {
+		panic("batch timeout must be greater than zero")
+	}
+
+	return &BatchWorker{
+		Handler: h,
+		Size:    size,
+		Timeout: timeout,
+		Logger:  logger.Discard,
+	}
+}
+
+func (bw *BatchWorker) Serve(ctx context.Context, _ Handler, msgCh <-chan amqp.Delivery) {
+	defer bw.Logger.Printf("[DEBUG] worker stopped")
+
+	timer := time.NewTimer(bw.Timeout)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	bw.Logger.Printf("[DEBUG] worker starting")
+
+	msgs := make([]amqp.Delivery, 0, bw.Size)
+	for {
+		select {
+		case msg, ok := <-msgCh:
+			if !ok {
+				if len(msgs) > 0 {
+					bw.handle(ctx, msgs)
+				}
+
+				return
+			}
+
+			msgs = append(msgs, msg)
+			if len(msgs) == 1 {
+				timer.Reset(bw.Timeout)
+			}
+			if len(msgs) < bw.Size {
+				continue
+			}
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+		case <-ctx.Done():
+			if len(msgs) > 0 {
+				bw.Logger.Printf("[DEBUG] worker dropped a batch of %d messages", len(msgs))
+			}
+
+			return
+		}
+
+		bw.handle(ctx, msgs)
+		msgs = make([]amqp.Delivery, 0, bw.Size)
+	}
+}
+
+func (bw *BatchWorker) handle(ctx context.Context, msgs []amqp.Delivery) {
+	results := bw.Handler.HandleBatch(ctx, msgs)
+
+	switch {
+	case len(results) == 1 && len(msgs) > 1:
+		bw.settleAll(msgs, results[0])
+	case len(results) == len(msgs):
+		for i, msg := range msgs {
+			Settle(bw.Logger, msg, results[i])
+		}
+	default:
+		bw.Logger.Printf("[ERROR] batch handler returned %d results for %d messages", len(results), len(msgs))
+		for _, msg := range msgs {
+			Settle(bw.Logger, msg, Requeue)
+		}
+	}
+}
+
+func (bw *BatchWorker) settleAll(msgs []amqp.Delivery, res Result) {
+	var err error
+
+	last := msgs[len(msgs)-1]
+	switch res.action {
+	case ackAction:
+		err = last.Ack(true)
+	case nackAction:
+		err = last.Nack(true, false)
+	case requeueAction:
+		err = last.Nack(true, true)
+	default:
+		for _, msg := range msgs {
+			Settle(bw.Logger, msg, res)
+		}
+
+		return
+	}
+
+	if err != nil {
+		bw.Logger.Printf("[ERROR] settle batch: %s", err)
+	}
+}
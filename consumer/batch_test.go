@@ -0,0 +1,261 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/logger"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestBatchWorker(main *testing.T) {
+	main.Run("WholeBatchSettledWithMultiple", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		l := logger.NewTest()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, msgs []amqp.Delivery) []consumer.Result {
+			l.Printf("[TEST] batch: %d", len(msgs))
+
+			return []consumer.Result{consumer.Ack}
+		}), 2, time.Hour)
+		w.Logger = l
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 3}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 4}
+
+		stop()
+
+		require.Equal(t, []string{"ack 2 true", "ack 4 true"}, a.calls())
+		require.Equal(t, `[DEBUG] worker starting
+[TEST] batch: 2
+[TEST] batch: 2
+[DEBUG] worker stopped
+`, l.Logs())
+	})
+
+	main.Run("PerMessageResultsOnTimeout", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		batchCh := make(chan int, 1)
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, msgs []amqp.Delivery) []consumer.Result {
+			batchCh <- len(msgs)
+
+			return []consumer.Result{consumer.Ack, consumer.Requeue}
+		}), 10, time.Millisecond*50)
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2}
+
+		select {
+		case n := <-batchCh:
+			require.Equal(t, 2, n)
+		case <-time.After(time.Millisecond * 200):
+			t.Fatal("batch must be flushed on timeout")
+		}
+
+		stop()
+
+		require.Equal(t, []string{"ack 1 false", "nack 2 false true"}, a.calls())
+	})
+
+	main.Run("PartialBatchDroppedOnStop", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, _ []amqp.Delivery) []consumer.Result {
+			t.Error("partial batch must not be handled once the worker is stopped")
+
+			return []consumer.Result{consumer.Ack}
+		}), 10, time.Hour)
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+
+		stop()
+
+		require.Empty(t, a.calls())
+	})
+
+	main.Run("PartialBatchFlushedOnDeliveriesClosed", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, msgs []amqp.Delivery) []consumer.Result {
+			require.Len(t, msgs, 1)
+
+			return []consumer.Result{consumer.Ack}
+		}), 10, time.Hour)
+
+		msgCh := make(chan amqp.Delivery)
+		doneCh := make(chan struct{})
+		go func() {
+			defer close(doneCh)
+			w.Serve(context.Background(), nil, msgCh)
+		}()
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		close(msgCh)
+		<-doneCh
+
+		require.Equal(t, []string{"ack 1 false"}, a.calls())
+	})
+
+	main.Run("RequeueIfResultsMismatch", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		l := logger.NewTest()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, _ []amqp.Delivery) []consumer.Result {
+			return []consumer.Result{consumer.Ack, consumer.Ack}
+		}), 3, time.Hour)
+		w.Logger = l
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 3}
+
+		stop()
+
+		require.Equal(t, []string{"nack 1 false true", "nack 2 false true", "nack 3 false true"}, a.calls())
+		require.Contains(t, l.Logs(), "[ERROR] batch handler returned 2 results for 3 messages\n")
+	})
+
+	main.Run("ErrorResultSettledPerMessage", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, _ []amqp.Delivery) []consumer.Result {
+			return []consumer.Result{consumer.Error(fmt.Errorf("the error"))}
+		}), 2, time.Hour)
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2}
+
+		stop()
+
+		require.Equal(t, []string{"nack 1 false false", "nack 2 false false"}, a.calls())
+	})
+
+	main.Run("RequeueIfNoResults", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		l := logger.NewTest()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, _ []amqp.Delivery) []consumer.Result {
+			return nil
+		}), 2, time.Hour)
+		w.Logger = l
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2}
+
+		stop()
+
+		require.Equal(t, []string{"nack 1 false true", "nack 2 false true"}, a.calls())
+		require.Contains(t, l.Logs(), "[ERROR] batch handler returned 0 results for 2 messages\n")
+	})
+
+	main.Run("SettledByHandler", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		l := logger.NewTest()
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, msgs []amqp.Delivery) []consumer.Result {
+			require.NoError(t, msgs[len(msgs)-1].Ack(true))
+
+			return []consumer.Result{consumer.Settled}
+		}), 2, time.Hour)
+		w.Logger = l
+
+		msgCh, stop := serveBatchWorker(w)
+
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+		msgCh <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2}
+
+		stop()
+
+		require.Equal(t, []string{"ack 2 true"}, a.calls())
+		require.NotContains(t, l.Logs(), "[ERROR]")
+	})
+}
+
+func TestConsumerWithBatchWorker(main *testing.T) {
+	main.Run("ErrorIfPrefetchLessThanBatchSize", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, _ []amqp.Delivery) []consumer.Result {
+			return nil
+		}), 10, time.Second)
+
+		_, err := consumer.New(
+			make(chan *consumer.Connection),
+			consumer.WithQueue("theQueue"),
+			consumer.WithWorker(w),
+			consumer.WithQos(5, false),
+		)
+		require.EqualError(t, err, "prefetch count must be not less than batch size")
+	})
+
+	main.Run("HandlerNotRequired", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		w := consumer.NewBatchWorker(consumer.BatchHandlerFunc(func(_ context.Context, _ []amqp.Delivery) []consumer.Result {
+			return nil
+		}), 10, time.Second)
+
+		c, err := consumer.New(
+			make(chan *consumer.Connection),
+			consumer.WithQueue("theQueue"),
+			consumer.WithWorker(w),
+			consumer.WithQos(10, false),
+		)
+		require.NoError(t, err)
+
+		c.Close()
+		<-c.NotifyClosed()
+	})
+}
+
+func serveBatchWorker(w *consumer.BatchWorker) (chan<- amqp.Delivery, func()) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+
+	msgCh := make(chan amqp.Delivery)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		w.Serve(ctx, nil, msgCh)
+	}()
+
+	return msgCh, func() {
+		cancelFunc()
+		<-doneCh
+	}
+}
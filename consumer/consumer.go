@@ -5,21 +5,35 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/makasim/amqpextra/backoff"
 	"github.com/makasim/amqpextra/logger"
 	"github.com/streadway/amqp"
 )
 
 var errChannelClosed = fmt.Errorf("channel closed")
+var errPaused = fmt.Errorf("paused")
+var errResumed = fmt.Errorf("resumed")
 
 type State struct {
 	Unready *Unready
 	Ready   *Ready
+	Paused  *Paused
 }
 
+// Ready tells the queues the consumer consumes from.
+// Queue is the first of Queues, Queues has several names if the consumer is set up WithQueues.
 type Ready struct {
-	Queue string
+	Queue  string
+	Queues []string
+}
+
+// Paused is the state of a consumer that keeps the channel open but does not consume, see Consumer.Pause.
+type Paused struct {
+	Queue  string
+	Queues []string
 }
 
 type Unready struct {
@@ -34,11 +48,15 @@ type AMQPChannel interface {
 	Qos(prefetchCount, prefetchSize int, global bool) error
 	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
 	NotifyCancel(c chan string) chan string
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
 	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
 	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Cancel(consumer string, noWait bool) error
 	Close() error
 }
 
+var consumerTagSeq uint64
+
 type Option func(c *Consumer)
 
 type Consumer struct {
@@ -47,12 +65,21 @@ type Consumer struct {
 
 	worker Worker
 
-	retryPeriod time.Duration
-	initFunc    func(conn AMQPConnection) (AMQPChannel, error)
-	ctx         context.Context
-	cancelFunc  context.CancelFunc
-	logger      logger.Logger
-	closeCh     chan struct{}
+	retryPeriod  time.Duration
+	backoff      backoff.Backoff
+	retry        *backoff.Retry
+	stablePeriod time.Duration
+	initFunc     func(conn AMQPConnection) (AMQPChannel, error)
+	ctx          context.Context
+	cancelFunc   context.CancelFunc
+	logger       logger.Logger
+	closeCh      chan struct{}
+
+	shutdownOnce sync.Once
+	shutdownCh   chan struct{}
+
+	paused  int32
+	pauseCh chan struct{}
 
 	mu       sync.Mutex
 	stateChs []chan State
@@ -64,8 +91,11 @@ type Consumer struct {
 
 	exchange   string
 	routingKey string
+	exchanges  []exchange
+	bindings   []binding
 
 	queue             string
+	queues            []string
 	queueDeclare      bool
 	declareDurable    bool
 	declareAutoDelete bool
@@ -81,6 +111,23 @@ type Consumer struct {
 	args      amqp.Table
 }
 
+type exchange struct {
+	name       string
+	kind       string
+	durable    bool
+	autoDelete bool
+	internal   bool
+	noWait     bool
+	args       amqp.Table
+}
+
+type binding struct {
+	queue    string
+	exchange string
+	key      string
+	args     amqp.Table
+}
+
 func New(
 	connCh <-chan *Connection,
 	opts ...Option,
@@ -90,7 +137,9 @@ func New(
 		internalStateCh: make(chan State),
 		prefetchCount:   1,
 
-		closeCh: make(chan struct{}),
+		closeCh:    make(chan struct{}),
+		shutdownCh: make(chan struct{}),
+		pauseCh:    make(chan struct{}, 1),
 	}
 
 	for _, opt := range opts {
@@ -117,6 +166,11 @@ func New(
 		c.retryPeriod = time.Second * 5
 	}
 
+	if c.backoff == nil {
+		c.backoff = backoff.Constant(c.retryPeriod)
+	}
+	c.retry = backoff.NewRetry(c.backoff, c.stablePeriod)
+
 	if c.logger == nil {
 		c.logger = logger.Discard
 	}
@@ -125,12 +179,24 @@ func New(
 		c.worker = &DefaultWorker{Logger: c.logger}
 	}
 
-	if c.handler == nil {
+	if bw, ok := c.worker.(*BatchWorker); ok {
+		if c.prefetchCount > 0 && c.prefetchCount < bw.Size {
+			return nil, fmt.Errorf("prefetch count must be not less than batch size")
+		}
+	} else if c.handler == nil {
 		return nil, fmt.Errorf("handler must be not nil")
 	}
 
-	if c.queue == "" && c.exchange == "" && !c.queueDeclare {
-		return nil, fmt.Errorf("WithQueue or WithExchange or WithDeclareQueue or WithTmpQueue options must be set")
+	if c.queue == "" && len(c.queues) == 0 && c.exchange == "" && !c.queueDeclare {
+		return nil, fmt.Errorf("WithQueue or WithQueues or WithExchange or WithDeclareQueue or WithTmpQueue options must be set")
+	}
+
+	if len(c.queues) > 0 {
+		for _, b := range c.bindings {
+			if b.queue == "" {
+				return nil, fmt.Errorf("WithBinding queue must be set if WithQueues is used")
+			}
+		}
 	}
 
 	if c.initFunc == nil {
@@ -159,6 +225,16 @@ func WithContext(ctx context.Context) Option {
 func WithRetryPeriod(dur time.Duration) Option {
 	return func(c *Consumer) {
 		c.retryPeriod = dur
+		c.backoff = nil
+	}
+}
+
+// WithBackoff configure how much time to wait before the consumer retries to set up a channel.
+// The backoff is reset once the consumer stayed ready for at least stablePeriod.
+func WithBackoff(b backoff.Backoff, stablePeriod time.Duration) Option {
+	return func(c *Consumer) {
+		c.backoff = b
+		c.stablePeriod = stablePeriod
 	}
 }
 
@@ -204,6 +280,49 @@ func WithQueue(queue string) Option {
 	}
 }
 
+// WithQueues consumes from several queues on one channel, the messages of all of them are passed to one worker.
+func WithQueues(queues ...string) Option {
+	return func(c *Consumer) {
+		c.resetSource()
+		for _, queue := range queues {
+			if queue != "" {
+				c.queues = append(c.queues, queue)
+			}
+		}
+	}
+}
+
+// WithBinding binds the queue to the exchange with the routing key once the channel is open.
+// An empty queue stands for the consumer queue, like the one declared by WithExchange, WithTmpQueue or WithDeclareQueue,
+// it must be set if WithQueues is used.
+// The option could be passed several times to bind the queues to several exchanges or keys.
+func WithBinding(queue, exchange, key string, args amqp.Table) Option {
+	return func(c *Consumer) {
+		c.bindings = append(c.bindings, binding{
+			queue:    queue,
+			exchange: exchange,
+			key:      key,
+			args:     args,
+		})
+	}
+}
+
+// WithDeclareExchange declares the exchange once the channel is open, before the queue is declared and bound.
+// The option could be passed several times to declare several exchanges, they are declared in the same order.
+func WithDeclareExchange(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) Option {
+	return func(c *Consumer) {
+		c.exchanges = append(c.exchanges, exchange{
+			name:       name,
+			kind:       kind,
+			durable:    durable,
+			autoDelete: autoDelete,
+			internal:   internal,
+			noWait:     noWait,
+			args:       args,
+		})
+	}
+}
+
 func WithTmpQueue() Option {
 	return func(c *Consumer) {
 		c.resetSource()
@@ -244,6 +363,7 @@ func WithConsumeArgs(consumer string, autoAck, exclusive, noLocal, noWait bool,
 
 func (c *Consumer) resetSource() {
 	c.queue = ""
+	c.queues = nil
 	c.queueDeclare = false
 	c.declareDurable = false
 	c.declareAutoDelete = false
@@ -281,6 +401,49 @@ func (c *Consumer) Close() {
 	c.cancelFunc()
 }
 
+// Pause cancels the consumption and keeps the channel open until Resume is called.
+// The messages already delivered are handled by the worker before the consumer becomes paused.
+// A consumer paused before it is ready stays paused once it gets a channel.
+func (c *Consumer) Pause() {
+	atomic.StoreInt32(&c.paused, 1)
+	c.signalPause()
+}
+
+// Resume starts the consumption stopped by Pause.
+func (c *Consumer) Resume() {
+	atomic.StoreInt32(&c.paused, 0)
+	c.signalPause()
+}
+
+func (c *Consumer) signalPause() {
+	select {
+	case c.pauseCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Consumer) isPaused() bool {
+	return atomic.LoadInt32(&c.paused) == 1
+}
+
+// Shutdown cancels the consumption, lets the worker handle the messages already delivered to it and closes the consumer.
+// If ctx is done first, the consumer is closed right away and the unsettled messages are redelivered by the broker.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() {
+		close(c.shutdownCh)
+	})
+
+	select {
+	case <-c.closeCh:
+		return nil
+	case <-ctx.Done():
+		c.Close()
+		<-c.closeCh
+
+		return fmt.Errorf("shutdown: %v", ctx.Err())
+	}
+}
+
 func (c *Consumer) connectionState() {
 	defer c.cancelFunc()
 	defer close(c.closeCh)
@@ -303,6 +466,8 @@ func (c *Consumer) connectionState() {
 				continue
 			case <-c.ctx.Done():
 				return
+			case <-c.shutdownCh:
+				return
 			default:
 			}
 
@@ -316,6 +481,8 @@ func (c *Consumer) connectionState() {
 			return
 		case <-c.ctx.Done():
 			return
+		case <-c.shutdownCh:
+			return
 		}
 	}
 }
@@ -333,23 +500,55 @@ func (c *Consumer) channelState(conn AMQPConnection, connCloseCh <-chan struct{}
 			return c.waitRetry(err)
 		}
 
-		queue := c.queue
-		if c.queueDeclare {
-			q, declareErr := ch.QueueDeclare(c.queue, c.declareDurable, c.declareAutoDelete, c.declareExclusive, c.declareNoWait, c.declareArgs)
-			if declareErr != nil {
-				return c.waitRetry(declareErr)
+		for _, e := range c.exchanges {
+			err = ch.ExchangeDeclare(e.name, e.kind, e.durable, e.autoDelete, e.internal, e.noWait, e.args)
+			if err != nil {
+				return c.waitRetry(err)
+			}
+		}
+
+		queues := c.queues
+		if len(queues) == 0 {
+			queue := c.queue
+			if c.queueDeclare {
+				q, declareErr := ch.QueueDeclare(c.queue, c.declareDurable, c.declareAutoDelete, c.declareExclusive, c.declareNoWait, c.declareArgs)
+				if declareErr != nil {
+					return c.waitRetry(declareErr)
+				}
+				queue = q.Name
+			}
+
+			if c.exchange != "" {
+				err = ch.QueueBind(queue, c.routingKey, c.exchange, false, nil)
+				if err != nil {
+					return c.waitRetry(err)
+				}
 			}
-			queue = q.Name
+
+			queues = []string{queue}
 		}
 
-		if c.exchange != "" {
-			err = ch.QueueBind(queue, c.routingKey, c.exchange, false, nil)
+		for _, b := range c.bindings {
+			queue := b.queue
+			if queue == "" {
+				queue = queues[0]
+			}
+
+			err = ch.QueueBind(queue, b.key, b.exchange, false, b.args)
 			if err != nil {
 				return c.waitRetry(err)
 			}
 		}
 
-		err = c.consumeState(ch, queue, connCloseCh)
+		err = errResumed
+		for err == errPaused || err == errResumed {
+			if c.isPaused() {
+				err = c.pausedState(ch, queues, connCloseCh)
+			} else {
+				err = c.consumeState(ch, queues, connCloseCh)
+			}
+		}
+
 		if err == errChannelClosed {
 			c.notifyUnready(err)
 			continue
@@ -359,19 +558,32 @@ func (c *Consumer) channelState(conn AMQPConnection, connCloseCh <-chan struct{}
 	}
 }
 
-func (c *Consumer) consumeState(ch AMQPChannel, queue string, connCloseCh <-chan struct{}) error {
-	msgCh, err := ch.Consume(
-		queue,
-		c.consumer,
-		c.autoAck,
-		c.exclusive,
-		c.noLocal,
-		c.noWait,
-		c.args,
-	)
-	if err != nil {
-		c.logger.Printf("[ERROR] ch.Consume: %s", err)
-		return c.waitRetry(err)
+func (c *Consumer) consumeState(ch AMQPChannel, queues []string, connCloseCh <-chan struct{}) error {
+	tags := make([]string, 0, len(queues))
+	msgChs := make([]<-chan amqp.Delivery, 0, len(queues))
+	for _, queue := range queues {
+		tag := c.consumerTag(queue, len(queues))
+
+		msgCh, err := ch.Consume(
+			queue,
+			tag,
+			c.autoAck,
+			c.exclusive,
+			c.noLocal,
+			c.noWait,
+			c.args,
+		)
+		if err != nil {
+			c.logger.Printf("[ERROR] ch.Consume: %s", err)
+			for _, tag := range tags {
+				_ = ch.Cancel(tag, false)
+			}
+
+			return c.waitRetry(err)
+		}
+
+		tags = append(tags, tag)
+		msgChs = append(msgChs, msgCh)
 	}
 
 	chCloseCh := ch.NotifyClose(make(chan *amqp.Error, 1))
@@ -383,8 +595,10 @@ func (c *Consumer) consumeState(ch AMQPChannel, queue string, connCloseCh <-chan
 
 	c.logger.Printf("[DEBUG] consumer ready")
 
-	state := c.notifyReady(queue)
+	c.retry.Ready()
+	state := c.notifyReady(queues)
 
+	msgCh := mergeDeliveries(workerCtx, msgChs)
 	go func() {
 		defer close(workerDoneCh)
 		c.worker.Serve(workerCtx, c.handler, msgCh)
@@ -406,27 +620,70 @@ func (c *Consumer) consumeState(ch AMQPChannel, queue string, connCloseCh <-chan
 			result = amqp.ErrClosed
 		case <-workerDoneCh:
 			result = fmt.Errorf("workers unexpectedly stopped")
+		case <-c.pauseCh:
+			if !c.isPaused() {
+				continue
+			}
+
+			result = c.drain(ch, tags, workerDoneCh, chCloseCh, connCloseCh)
+			if result == nil && c.ctx.Err() == nil {
+				result = errPaused
+			}
+		case <-c.shutdownCh:
+			_ = c.drain(ch, tags, workerDoneCh, chCloseCh, connCloseCh)
+			result = nil
 		case <-c.ctx.Done():
 			result = nil
 		}
 
 		workerCancelFunc()
 		<-workerDoneCh
-		c.close(ch)
+		if result != errPaused {
+			c.close(ch)
+		}
 
 		return result
 	}
 }
 
-func (c *Consumer) waitRetry(err error) error {
-	timer := time.NewTimer(c.retryPeriod)
-	defer func() {
-		timer.Stop()
+func (c *Consumer) pausedState(ch AMQPChannel, queues []string, connCloseCh <-chan struct{}) error {
+	chCloseCh := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	c.logger.Printf("[DEBUG] consumer paused")
+	state := c.notifyPaused(queues)
+
+	var result error
+	for {
 		select {
-		case <-timer.C:
-		default:
+		case c.internalStateCh <- state:
+			continue
+		case <-c.pauseCh:
+			if c.isPaused() {
+				continue
+			}
+
+			c.logger.Printf("[DEBUG] consumer resumed")
+			return errResumed
+		case <-chCloseCh:
+			c.logger.Printf("[DEBUG] channel closed")
+			result = errChannelClosed
+		case <-connCloseCh:
+			result = amqp.ErrClosed
+		case <-c.shutdownCh:
+			result = nil
+		case <-c.ctx.Done():
+			result = nil
 		}
-	}()
+
+		c.close(ch)
+
+		return result
+	}
+}
+
+func (c *Consumer) waitRetry(err error) error {
+	timer := c.retry.Timer()
+	defer backoff.Stop(timer)
 	state := c.notifyUnready(err)
 	for {
 		select {
@@ -436,13 +693,63 @@ func (c *Consumer) waitRetry(err error) error {
 			return err
 		case <-c.ctx.Done():
 			return nil
+		case <-c.shutdownCh:
+			return nil
 		}
 	}
 }
 
-func (c *Consumer) notifyReady(queue string) State {
+// drain cancels the consumption so the server stops sending messages,
+// and waits for the worker to handle the messages already delivered, the delivery channel is closed after them.
+// It returns an error if the channel or connection is lost meanwhile.
+func (c *Consumer) drain(
+	ch AMQPChannel,
+	tags []string,
+	workerDoneCh <-chan struct{},
+	chCloseCh <-chan *amqp.Error,
+	connCloseCh <-chan struct{},
+) error {
+	c.logger.Printf("[DEBUG] consumer draining")
+
+	for _, tag := range tags {
+		if err := ch.Cancel(tag, false); err != nil {
+			c.logger.Printf("[ERROR] ch.Cancel: %s", err)
+			return err
+		}
+	}
+
+	select {
+	case <-workerDoneCh:
+		return nil
+	case <-chCloseCh:
+		c.logger.Printf("[DEBUG] channel closed")
+		return errChannelClosed
+	case <-connCloseCh:
+		return amqp.ErrClosed
+	case <-c.ctx.Done():
+		return nil
+	}
+}
+
+func (c *Consumer) notifyReady(queues []string) State {
+	state := State{
+		Ready: &Ready{Queue: queues[0], Queues: queues},
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stateCh := range c.stateChs {
+		select {
+		case stateCh <- state:
+		case <-stateCh:
+			stateCh <- state
+		}
+	}
+	return state
+}
+
+func (c *Consumer) notifyPaused(queues []string) State {
 	state := State{
-		Ready: &Ready{Queue: queue},
+		Paused: &Paused{Queue: queues[0], Queues: queues},
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -472,6 +779,65 @@ func (c *Consumer) notifyUnready(err error) State {
 	return state
 }
 
+// consumerTag returns the tag set by WithConsumeArgs, suffixed with the queue name if there are several queues,
+// since the tags must be unique within a channel.
+func (c *Consumer) consumerTag(queue string, queuesNum int) string {
+	if c.consumer == "" {
+		return uniqueConsumerTag()
+	}
+	if queuesNum > 1 {
+		return c.consumer + "-" + queue
+	}
+
+	return c.consumer
+}
+
+// mergeDeliveries forwards messages of all the channels to one, it is closed once they all are closed.
+// Forwarding stops if ctx is done, the channel is about to be closed then and the messages not forwarded are redelivered.
+func mergeDeliveries(ctx context.Context, msgChs []<-chan amqp.Delivery) <-chan amqp.Delivery {
+	if len(msgChs) == 1 {
+		return msgChs[0]
+	}
+
+	mergedCh := make(chan amqp.Delivery)
+
+	var wg sync.WaitGroup
+	wg.Add(len(msgChs))
+	for _, msgCh := range msgChs {
+		go func(msgCh <-chan amqp.Delivery) {
+			defer wg.Done()
+
+			for {
+				select {
+				case msg, ok := <-msgCh:
+					if !ok {
+						return
+					}
+
+					select {
+					case mergedCh <- msg:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(msgCh)
+	}
+
+	go func() {
+		wg.Wait()
+		close(mergedCh)
+	}()
+
+	return mergedCh
+}
+
+func uniqueConsumerTag() string {
+	return fmt.Sprintf("ctag-amqpextra-%d", atomic.AddUint64(&consumerTagSeq, 1))
+}
+
 func (c *Consumer) close(ch AMQPChannel) {
 	if ch != nil {
 		if err := ch.Close(); err != nil && !strings.Contains(err.Error(), "channel/connection is not open") {
@@ -636,7 +636,7 @@ func TestConsume(main *testing.T) {
 		msgCh := make(chan amqp.Delivery)
 
 		ch := mock_consumer.NewMockAMQPChannel(ctrl)
-		ch.EXPECT().Consume("theQueue", "", false, false, false, false, amqp.Table(nil)).
+		ch.EXPECT().Consume("theQueue", gomock.Not(""), false, false, false, false, amqp.Table(nil)).
 			Return(msgCh, nil).Times(1)
 		ch.EXPECT().NotifyClose(any()).
 			Return(chCloseCh).Times(1)
@@ -1503,6 +1503,363 @@ func TestOptions(main *testing.T) {
 `, l.Logs())
 	})
 
+	main.Run("ReadyWithQueues", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		gotCh := make(chan string, 3)
+		h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
+			gotCh <- msg.ConsumerTag
+
+			return consumer.Settled
+		})
+
+		stateCh := make(chan consumer.State, 2)
+		fooMsgCh := make(chan amqp.Delivery)
+		barMsgCh := make(chan amqp.Delivery)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			Consume("fooQueue", "theConsumer-fooQueue", any(), any(), any(), any(), any()).
+			Return(fooMsgCh, nil).
+			Times(1)
+		ch.EXPECT().
+			Consume("barQueue", "theConsumer-barQueue", any(), any(), any(), any(), any()).
+			Return(barMsgCh, nil).
+			Times(1)
+		ch.EXPECT().Qos(any(), any(), any()).
+			Times(1)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+		ch.EXPECT().
+			NotifyCancel(any()).
+			AnyTimes()
+		ch.EXPECT().
+			Close().
+			Times(1)
+
+		conn := mock_consumer.NewMockAMQPConnection(ctrl)
+
+		connCh := make(chan *consumer.Connection, 1)
+
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueues("fooQueue", "barQueue"),
+			consumer.WithConsumeArgs("theConsumer", false, false, false, false, nil),
+			consumer.WithHandler(h),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(conn, nil)
+
+		select {
+		case state := <-stateCh:
+			require.NotNil(t, state.Ready, fmt.Sprintf("%+v", state))
+			require.Equal(t, "fooQueue", state.Ready.Queue)
+			require.Equal(t, []string{"fooQueue", "barQueue"}, state.Ready.Queues)
+		case <-time.NewTimer(time.Millisecond * 100).C:
+			t.Fatal("consumer must be ready")
+		}
+
+		fooMsgCh <- amqp.Delivery{ConsumerTag: "theConsumer-fooQueue"}
+		barMsgCh <- amqp.Delivery{ConsumerTag: "theConsumer-barQueue"}
+		fooMsgCh <- amqp.Delivery{ConsumerTag: "theConsumer-fooQueue"}
+
+		var got []string
+		for i := 0; i < 3; i++ {
+			select {
+			case tag := <-gotCh:
+				got = append(got, tag)
+			case <-time.NewTimer(time.Millisecond * 100).C:
+				t.Fatal("message must be handled")
+			}
+		}
+		assert.ElementsMatch(t, []string{"theConsumer-fooQueue", "theConsumer-barQueue", "theConsumer-fooQueue"}, got)
+
+		c.Close()
+		assertClosed(t, c)
+	})
+
+	main.Run("BindQueueWithBindings", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		h := handlerStub(l)
+
+		stateCh := make(chan consumer.State, 2)
+		msgCh := make(chan amqp.Delivery)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			Consume("theQueue", any(), any(), any(), any(), any(), any()).
+			Return(msgCh, nil).
+			Times(1)
+		ch.EXPECT().Qos(any(), any(), any()).
+			Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				QueueDeclare("theQueue", true, false, false, false, any()).
+				Return(amqp.Queue{Name: "theQueue"}, nil),
+			ch.EXPECT().
+				QueueBind("theQueue", "fooKey", "fooExchange", false, amqp.Table{"foo": "fooVal"}).
+				Return(nil),
+			ch.EXPECT().
+				QueueBind("theQueue", "barKey", "barExchange", false, nil).
+				Return(nil),
+		)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+		ch.EXPECT().
+			NotifyCancel(any()).
+			AnyTimes()
+		ch.EXPECT().
+			Close().
+			Times(1)
+
+		conn := mock_consumer.NewMockAMQPConnection(ctrl)
+
+		connCh := make(chan *consumer.Connection, 1)
+
+		c, err := consumer.New(
+			connCh,
+			consumer.WithBinding("", "fooExchange", "fooKey", amqp.Table{"foo": "fooVal"}),
+			consumer.WithDeclareQueue("theQueue", true, false, false, false, nil),
+			consumer.WithBinding("theQueue", "barExchange", "barKey", nil),
+			consumer.WithHandler(h),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(conn, nil)
+		assertReady(t, stateCh, "theQueue")
+
+		c.Close()
+		assertClosed(t, c)
+	})
+
+	main.Run("DeclareTopologyOnEveryChannel", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		h := handlerStub(l)
+
+		stateCh := make(chan consumer.State, 2)
+		chCloseCh := make(chan *amqp.Error, 1)
+
+		expectTopology := func(ch *mock_consumer.MockAMQPChannel) {
+			gomock.InOrder(
+				ch.EXPECT().
+					ExchangeDeclare("fooExchange", "topic", true, false, false, false, nil).
+					Return(nil),
+				ch.EXPECT().
+					ExchangeDeclare("barExchange", "headers", true, false, false, false, amqp.Table{"bar": "barVal"}).
+					Return(nil),
+				ch.EXPECT().
+					QueueDeclare("theQueue", true, false, false, false, any()).
+					Return(amqp.Queue{Name: "theQueue"}, nil),
+				ch.EXPECT().
+					QueueBind("theQueue", "foo.*", "fooExchange", false, nil).
+					Return(nil),
+				ch.EXPECT().
+					QueueBind("theQueue", "", "barExchange", false, amqp.Table{"x-match": "all", "bar": "barVal"}).
+					Return(nil),
+			)
+			ch.EXPECT().
+				Consume("theQueue", any(), any(), any(), any(), any(), any()).
+				Return(make(chan amqp.Delivery), nil).
+				Times(1)
+			ch.EXPECT().Qos(any(), any(), any()).
+				Times(1)
+			ch.EXPECT().
+				NotifyCancel(any()).
+				AnyTimes()
+			ch.EXPECT().
+				Close().
+				Times(1)
+		}
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		expectTopology(ch)
+		ch.EXPECT().
+			NotifyClose(any()).
+			Return(chCloseCh).
+			Times(1)
+
+		ch2 := mock_consumer.NewMockAMQPChannel(ctrl)
+		expectTopology(ch2)
+		ch2.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+
+		conn := mock_consumer.NewMockAMQPConnection(ctrl)
+
+		connCh := make(chan *consumer.Connection, 1)
+
+		c, err := consumer.New(
+			connCh,
+			consumer.WithDeclareExchange("fooExchange", "topic", true, false, false, false, nil),
+			consumer.WithDeclareExchange("barExchange", "headers", true, false, false, false, amqp.Table{"bar": "barVal"}),
+			consumer.WithDeclareQueue("theQueue", true, false, false, false, nil),
+			consumer.WithBinding("", "fooExchange", "foo.*", nil),
+			consumer.WithBinding("", "barExchange", "", amqp.Table{"x-match": "all", "bar": "barVal"}),
+			consumer.WithHandler(h),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch, ch2)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(conn, nil)
+		assertReady(t, stateCh, "theQueue")
+
+		chCloseCh <- amqp.ErrClosed
+		assertUnready(t, stateCh, "channel closed")
+		assertReady(t, stateCh, "theQueue")
+
+		c.Close()
+		assertClosed(t, c)
+	})
+
+	main.Run("WaitIfExchangeDeclareErrored", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		h := handlerStub(l)
+
+		stateCh := make(chan consumer.State, 2)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			ExchangeDeclare(any(), any(), any(), any(), any(), any(), any()).
+			Return(fmt.Errorf("the error"))
+		ch.EXPECT().Qos(any(), any(), any()).
+			Times(1)
+		ch.EXPECT().
+			Close().
+			AnyTimes()
+
+		conn := mock_consumer.NewMockAMQPConnection(ctrl)
+
+		connCh := make(chan *consumer.Connection, 1)
+		connCh <- consumer.NewConnection(conn, nil)
+
+		c, err := consumer.New(
+			connCh,
+			consumer.WithDeclareExchange("theExchange", "direct", true, false, false, false, nil),
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(h),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithRetryPeriod(time.Millisecond*100),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		time.Sleep(time.Millisecond * 50)
+		assertUnready(t, stateCh, "the error")
+
+		c.Close()
+		assertClosed(t, c)
+
+		assert.Equal(t, `[DEBUG] consumer starting
+[DEBUG] consumer unready
+[DEBUG] consumer stopped
+`, l.Logs())
+	})
+
+	main.Run("ErroredIfBindingWithoutQueueWithQueues", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, err := consumer.New(
+			make(chan *consumer.Connection),
+			consumer.WithQueues("fooQueue", "barQueue"),
+			consumer.WithBinding("fooQueue", "theExchange", "fooKey", nil),
+			consumer.WithBinding("", "theExchange", "theKey", nil),
+			consumer.WithHandler(handlerStub(logger.NewTest())),
+		)
+		require.EqualError(t, err, "WithBinding queue must be set if WithQueues is used")
+	})
+
+	main.Run("BindQueuesWithBindings", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		h := handlerStub(l)
+
+		stateCh := make(chan consumer.State, 2)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().Qos(any(), any(), any()).
+			Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				QueueBind("fooQueue", "fooKey", "theExchange", false, nil).
+				Return(nil),
+			ch.EXPECT().
+				QueueBind("barQueue", "barKey", "theExchange", false, amqp.Table{"bar": "barVal"}).
+				Return(nil),
+			ch.EXPECT().
+				Consume("fooQueue", any(), any(), any(), any(), any(), any()).
+				Return(make(chan amqp.Delivery), nil),
+			ch.EXPECT().
+				Consume("barQueue", any(), any(), any(), any(), any(), any()).
+				Return(make(chan amqp.Delivery), nil),
+		)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+		ch.EXPECT().
+			NotifyCancel(any()).
+			AnyTimes()
+		ch.EXPECT().
+			Close().
+			Times(1)
+
+		conn := mock_consumer.NewMockAMQPConnection(ctrl)
+
+		connCh := make(chan *consumer.Connection, 1)
+
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueues("fooQueue", "barQueue"),
+			consumer.WithBinding("fooQueue", "theExchange", "fooKey", nil),
+			consumer.WithBinding("barQueue", "theExchange", "barKey", amqp.Table{"bar": "barVal"}),
+			consumer.WithHandler(h),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(conn, nil)
+		assertReady(t, stateCh, "fooQueue")
+
+		c.Close()
+		assertClosed(t, c)
+	})
+
 	main.Run("WaitIfQueueDeclareErrored", func(t *testing.T) {
 		defer goleak.VerifyNone(t)
 
@@ -1712,7 +2069,7 @@ func TestOptions(main *testing.T) {
 			connCh,
 			consumer.WithHandler(h),
 		)
-		require.EqualError(t, err, "WithQueue or WithExchange or WithDeclareQueue or WithTmpQueue options must be set")
+		require.EqualError(t, err, "WithQueue or WithQueues or WithExchange or WithDeclareQueue or WithTmpQueue options must be set")
 	})
 
 	main.Run("DeclareTemporaryQueueIfWithExchange", func(t *testing.T) {
@@ -1989,6 +2346,283 @@ func TestOptions(main *testing.T) {
 	})
 }
 
+func TestShutdown(main *testing.T) {
+	main.Run("DrainDeliveredMessages", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
+			time.Sleep(time.Millisecond * 10)
+			l.Printf("[TEST] handled %s", msg.Body)
+
+			return consumer.Settled
+		})
+
+		stateCh := make(chan consumer.State, 2)
+		msgCh := make(chan amqp.Delivery, 2)
+		msgCh <- amqp.Delivery{Body: []byte("first")}
+		msgCh <- amqp.Delivery{Body: []byte("second")}
+
+		var tag string
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().Qos(any(), any(), any()).Times(1)
+		ch.EXPECT().Consume("theQueue", any(), any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, consumerTag string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+				tag = consumerTag
+
+				return msgCh, nil
+			}).Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyCancel(any()).AnyTimes()
+		ch.EXPECT().Cancel(any(), false).
+			DoAndReturn(func(consumerTag string, _ bool) error {
+				require.Equal(t, tag, consumerTag)
+				close(msgCh)
+
+				return nil
+			}).Times(1)
+		ch.EXPECT().Close().Times(1)
+
+		connCh := make(chan *consumer.Connection, 1)
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(h),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(mock_consumer.NewMockAMQPConnection(ctrl), nil)
+		assertReady(t, stateCh, "theQueue")
+
+		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second)
+		defer cancelFunc()
+
+		require.NoError(t, c.Shutdown(ctx))
+		assertClosed(t, c)
+
+		logs := l.Logs()
+		assert.Contains(t, logs, "[DEBUG] consumer draining\n")
+		assert.Contains(t, logs, `[TEST] handled first
+[TEST] handled second
+[DEBUG] worker stopped
+[DEBUG] consumer unready
+[DEBUG] consumer stopped
+`)
+	})
+
+	main.Run("CloseOnDeadline", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		stateCh := make(chan consumer.State, 2)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().Qos(any(), any(), any()).Times(1)
+		ch.EXPECT().Consume(any(), any(), any(), any(), any(), any(), any()).
+			Return(make(chan amqp.Delivery), nil).Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyCancel(any()).AnyTimes()
+		ch.EXPECT().Cancel(any(), false).Return(nil).Times(1)
+		ch.EXPECT().Close().Times(1)
+
+		connCh := make(chan *consumer.Connection, 1)
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(handlerStub(l)),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(mock_consumer.NewMockAMQPConnection(ctrl), nil)
+		assertReady(t, stateCh, "theQueue")
+
+		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancelFunc()
+
+		require.EqualError(t, c.Shutdown(ctx), "shutdown: context deadline exceeded")
+		assertClosed(t, c)
+	})
+
+	main.Run("NotReady", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		c, err := consumer.New(
+			make(chan *consumer.Connection),
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(handlerStub(logger.Discard)),
+		)
+		require.NoError(t, err)
+
+		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancelFunc()
+
+		require.NoError(t, c.Shutdown(ctx))
+		assertClosed(t, c)
+	})
+}
+
+func TestPause(main *testing.T) {
+	main.Run("PauseAndResume", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		stateCh := make(chan consumer.State, 2)
+		firstMsgCh := make(chan amqp.Delivery)
+		secondMsgCh := make(chan amqp.Delivery)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().Qos(any(), any(), any()).Times(1)
+		gomock.InOrder(
+			ch.EXPECT().Consume("theQueue", any(), any(), any(), any(), any(), any()).
+				Return(firstMsgCh, nil).Times(1),
+			ch.EXPECT().Cancel(any(), false).
+				DoAndReturn(func(_ string, _ bool) error {
+					close(firstMsgCh)
+
+					return nil
+				}).Times(1),
+			ch.EXPECT().Consume("theQueue", any(), any(), any(), any(), any(), any()).
+				Return(secondMsgCh, nil).Times(1),
+			ch.EXPECT().Close().Times(1),
+		)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyCancel(any()).AnyTimes()
+
+		connCh := make(chan *consumer.Connection, 1)
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(handlerStub(l)),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		connCh <- consumer.NewConnection(mock_consumer.NewMockAMQPConnection(ctrl), nil)
+		assertReady(t, stateCh, "theQueue")
+
+		c.Pause()
+		assertPaused(t, stateCh, "theQueue")
+
+		c.Resume()
+		assertReady(t, stateCh, "theQueue")
+
+		c.Close()
+		assertClosed(t, c)
+
+		logs := l.Logs()
+		assert.Contains(t, logs, "[DEBUG] consumer draining\n")
+		assert.Contains(t, logs, `[DEBUG] worker stopped
+[DEBUG] consumer paused
+[DEBUG] consumer resumed
+[DEBUG] consumer ready
+`)
+		assert.Contains(t, logs, `[DEBUG] consumer unready
+[DEBUG] consumer stopped
+`)
+	})
+
+	main.Run("PausedBeforeReady", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		stateCh := make(chan consumer.State, 2)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().Qos(any(), any(), any()).Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().Close().Times(1)
+
+		connCh := make(chan *consumer.Connection, 1)
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(handlerStub(l)),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch)),
+		)
+		require.NoError(t, err)
+
+		c.Pause()
+
+		connCh <- consumer.NewConnection(mock_consumer.NewMockAMQPConnection(ctrl), nil)
+		assertPaused(t, stateCh, "theQueue")
+
+		c.Close()
+		assertClosed(t, c)
+
+		assert.Equal(t, `[DEBUG] consumer starting
+[DEBUG] consumer paused
+[DEBUG] consumer unready
+[DEBUG] consumer stopped
+`, l.Logs())
+	})
+
+	main.Run("StayPausedAfterChannelReopened", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		stateCh := make(chan consumer.State, 2)
+		chCloseCh := make(chan *amqp.Error, 1)
+
+		ch := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().Qos(any(), any(), any()).Times(1)
+		ch.EXPECT().NotifyClose(any()).Return(chCloseCh).Times(1)
+		ch.EXPECT().Close().Times(1)
+
+		ch2 := mock_consumer.NewMockAMQPChannel(ctrl)
+		ch2.EXPECT().Qos(any(), any(), any()).Times(1)
+		ch2.EXPECT().NotifyClose(any()).AnyTimes()
+		ch2.EXPECT().Close().Times(1)
+
+		connCh := make(chan *consumer.Connection, 1)
+		c, err := consumer.New(
+			connCh,
+			consumer.WithQueue("theQueue"),
+			consumer.WithHandler(handlerStub(l)),
+			consumer.WithLogger(l),
+			consumer.WithNotify(stateCh),
+			consumer.WithInitFunc(initFuncStub(ch, ch2)),
+		)
+		require.NoError(t, err)
+
+		c.Pause()
+
+		connCh <- consumer.NewConnection(mock_consumer.NewMockAMQPConnection(ctrl), nil)
+		assertPaused(t, stateCh, "theQueue")
+
+		chCloseCh <- amqp.ErrClosed
+		assertUnready(t, stateCh, "channel closed")
+		assertPaused(t, stateCh, "theQueue")
+
+		c.Close()
+		assertClosed(t, c)
+	})
+}
+
 func assertUnready(t *testing.T, stateCh <-chan consumer.State, errString string) {
 	timer := time.NewTimer(time.Millisecond * 100)
 	defer timer.Stop()
@@ -2032,6 +2666,22 @@ func assertReady(t *testing.T, stateCh <-chan consumer.State, queue string) {
 	}
 }
 
+func assertPaused(t *testing.T, stateCh <-chan consumer.State, queue string) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	select {
+	case state := <-stateCh:
+		require.Nil(t, state.Unready, fmt.Sprintf("%+v", state))
+		require.Nil(t, state.Ready, fmt.Sprintf("%+v", state))
+		require.NotNil(t, state.Paused, fmt.Sprintf("%+v", state))
+
+		require.Equal(t, state.Paused.Queue, queue)
+	case <-timer.C:
+		t.Fatal("consumer must be paused")
+	}
+}
+
 func assertClosed(t *testing.T, c *consumer.Consumer) {
 	timer := time.NewTimer(time.Millisecond * 100)
 	defer timer.Stop()
@@ -2048,17 +2698,17 @@ func any() gomock.Matcher {
 }
 
 func handlerStub(l logger.Logger) consumer.Handler {
-	return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		l.Printf("[TEST] got message")
-		return nil
+		return consumer.Settled
 	})
 }
 
 func handlerCounter(counter *int) consumer.Handler {
-	return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		time.Sleep(time.Millisecond * 10)
 		*counter++
-		return nil
+		return consumer.Settled
 	})
 }
 
@@ -9,12 +9,12 @@ import (
 type Middleware func(next Handler) Handler
 
 type Handler interface {
-	Handle(ctx context.Context, msg amqp.Delivery) interface{}
+	Handle(ctx context.Context, msg amqp.Delivery) Result
 }
 
-type HandlerFunc func(ctx context.Context, msg amqp.Delivery) interface{}
+type HandlerFunc func(ctx context.Context, msg amqp.Delivery) Result
 
-func (f HandlerFunc) Handle(ctx context.Context, msg amqp.Delivery) interface{} {
+func (f HandlerFunc) Handle(ctx context.Context, msg amqp.Delivery) Result {
 	return f(ctx, msg)
 }
 
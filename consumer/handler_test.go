@@ -2,6 +2,7 @@ package consumer_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/makasim/amqpextra/consumer"
@@ -15,12 +16,10 @@ import (
 func ExampleWrap() {
 	// wrap a handler by some middlewares
 	consumer.Wrap(
-		consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+		consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 			// process message
 
-			msg.Ack(false)
-
-			return nil
+			return consumer.Ack
 		}),
 		middleware.HasCorrelationID(),
 		middleware.HasReplyTo(),
@@ -37,10 +36,10 @@ func TestWrap(t *testing.T) {
 
 	expectedMsg := amqp.Delivery{Body: []byte(`theBody`)}
 
-	expectedResult := "theResult"
+	expectedResult := consumer.Error(fmt.Errorf("the error"))
 
 	handler := consumer.Wrap(
-		consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+		consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 			assert.Equal(t, expectedCtx, ctx)
 			assert.Equal(t, expectedMsg, msg)
 
@@ -48,7 +47,7 @@ func TestWrap(t *testing.T) {
 			return expectedResult
 		}),
 		func(next consumer.Handler) consumer.Handler {
-			return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+			return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 				assert.Equal(t, expectedCtx, ctx)
 				assert.Equal(t, expectedMsg, msg)
 
@@ -60,7 +59,7 @@ func TestWrap(t *testing.T) {
 			})
 		},
 		func(next consumer.Handler) consumer.Handler {
-			return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+			return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 				assert.Equal(t, expectedCtx, ctx)
 				assert.Equal(t, expectedMsg, msg)
 
@@ -8,17 +8,19 @@ import (
 	"github.com/streadway/amqp"
 )
 
-const Ack = "ack"
-const Nack = "nack_requeue"
-const Requeue = "requeue"
+// Deprecated: use consumer.Ack, consumer.Nack and consumer.Requeue.
+var (
+	Ack     = consumer.Ack
+	Nack    = consumer.Nack
+	Requeue = consumer.Requeue
+)
 
+// AckNack settles Ack, Nack and Requeue results and logs the outcome, other results are passed up.
+// Workers settle results on their own, the middleware is kept for the logging.
 func AckNack() consumer.Middleware {
 	return func(next consumer.Handler) consumer.Handler {
-		fn := func(ctx context.Context, msg amqp.Delivery) interface{} {
+		fn := func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 			result := next.Handle(ctx, msg)
-			if result == nil {
-				return nil
-			}
 
 			l, ok := GetLogger(ctx)
 			if !ok {
@@ -30,32 +32,32 @@ func AckNack() consumer.Middleware {
 				if err := msg.Ack(false); err != nil {
 					l.Printf("[ERROR] message ack errored", err)
 
-					return nil
+					return consumer.Settled
 				}
 
 				l.Printf("[DEBUG] message acked")
 
-				return nil
+				return consumer.Settled
 			case Nack:
 				if err := msg.Nack(false, false); err != nil {
 					l.Printf("[ERROR] message nack errored", err)
 
-					return nil
+					return consumer.Settled
 				}
 
 				l.Printf("[DEBUG] message nacked")
 
-				return nil
+				return consumer.Settled
 			case Requeue:
 				if err := msg.Nack(false, true); err != nil {
 					l.Printf("[ERROR] message nack requeue errored", err)
 
-					return nil
+					return consumer.Settled
 				}
 
 				l.Printf("[DEBUG] message requeue")
 
-				return nil
+				return consumer.Settled
 			}
 
 			return result
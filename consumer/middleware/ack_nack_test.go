@@ -6,6 +6,7 @@ import (
 
 	"fmt"
 
+	"github.com/makasim/amqpextra/consumer"
 	"github.com/makasim/amqpextra/consumer/middleware"
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
@@ -14,7 +15,7 @@ import (
 )
 
 func TestAckNack(main *testing.T) {
-	main.Run("DoNothingIfResultNil", func(t *testing.T) {
+	main.Run("DoNothingIfResultSettled", func(t *testing.T) {
 		a := acknowledgerMock{}
 		defer a.AssertExpectations(t)
 
@@ -24,10 +25,10 @@ func TestAckNack(main *testing.T) {
 
 		ctx := context.Background()
 
-		handler := middleware.AckNack()(dummyHandler(nil))
+		handler := middleware.AckNack()(dummyHandler(consumer.Settled))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 	})
 
 	main.Run("ResultNack", func(t *testing.T) {
@@ -46,7 +47,7 @@ func TestAckNack(main *testing.T) {
 		handler := middleware.AckNack()(dummyHandler(middleware.Nack))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 	})
 
 	main.Run("NackErrored", func(t *testing.T) {
@@ -68,7 +69,7 @@ func TestAckNack(main *testing.T) {
 		handler := middleware.AckNack()(dummyHandler(middleware.Nack))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 
 		require.Len(t, l.Formats, 1)
 		assert.Equal(t, "[ERROR] message nack errored", l.Formats[0])
@@ -93,7 +94,7 @@ func TestAckNack(main *testing.T) {
 		handler := middleware.AckNack()(dummyHandler(middleware.Requeue))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 	})
 
 	main.Run("RequeueErrored", func(t *testing.T) {
@@ -115,7 +116,7 @@ func TestAckNack(main *testing.T) {
 		handler := middleware.AckNack()(dummyHandler(middleware.Requeue))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 
 		require.Len(t, l.Formats, 1)
 		assert.Equal(t, "[ERROR] message nack requeue errored", l.Formats[0])
@@ -140,7 +141,7 @@ func TestAckNack(main *testing.T) {
 		handler := middleware.AckNack()(dummyHandler(middleware.Ack))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 	})
 
 	main.Run("AckErrored", func(t *testing.T) {
@@ -162,7 +163,7 @@ func TestAckNack(main *testing.T) {
 		handler := middleware.AckNack()(dummyHandler(middleware.Ack))
 
 		res := handler.Handle(ctx, msg)
-		assert.Nil(t, res)
+		assert.Equal(t, consumer.Settled, res)
 
 		require.Len(t, l.Formats, 1)
 		assert.Equal(t, "[ERROR] message ack errored", l.Formats[0])
@@ -180,7 +181,7 @@ func TestAckNack(main *testing.T) {
 		msg.CorrelationId = "theID"
 		msg.Acknowledger = &a
 
-		expected := struct{}{}
+		expected := consumer.Reject
 
 		ctx := context.Background()
 
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/streadway/amqp"
+)
+
+var deliveryHistoryKey = &contextKey{"delivery_history"}
+
+// Death is an x-death header entry, the broker adds one per queue and reason the message was dead-lettered from.
+type Death struct {
+	Queue       string
+	Reason      string
+	Exchange    string
+	RoutingKeys []string
+	Count       int64
+	Time        time.Time
+}
+
+// DeliveryHistory tells how many times the message has been delivered before.
+type DeliveryHistory struct {
+	// DeliveryCount is the x-delivery-count header quorum queues set on redelivery.
+	DeliveryCount int64
+	Redelivered   bool
+	Deaths        []Death
+}
+
+// Redeliveries returns how many times the message has been delivered before,
+// every requeue counted by a quorum queue and every dead-lettering count as one.
+// Dead-letterings for expiry are not counted, the message has not been delivered from that queue,
+// like from the retry queues of the Retry middleware.
+// If there are no counters, a redelivered message counts as delivered once before.
+func (h DeliveryHistory) Redeliveries() int64 {
+	n := h.DeliveryCount
+	for _, d := range h.Deaths {
+		if d.Reason == "expired" {
+			continue
+		}
+
+		n += d.Count
+	}
+
+	if n == 0 && h.Redelivered {
+		return 1
+	}
+
+	return n
+}
+
+func NewDeliveryHistory(msg amqp.Delivery) DeliveryHistory {
+	h := DeliveryHistory{
+		DeliveryCount: toInt64(msg.Headers["x-delivery-count"]),
+		Redelivered:   msg.Redelivered,
+	}
+
+	deaths, _ := msg.Headers["x-death"].([]interface{})
+	for _, v := range deaths {
+		t, ok := v.(amqp.Table)
+		if !ok {
+			continue
+		}
+
+		d := Death{
+			Count: toInt64(t["count"]),
+		}
+		d.Queue, _ = t["queue"].(string)
+		d.Reason, _ = t["reason"].(string)
+		d.Exchange, _ = t["exchange"].(string)
+		d.Time, _ = t["time"].(time.Time)
+
+		keys, _ := t["routing-keys"].([]interface{})
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				d.RoutingKeys = append(d.RoutingKeys, key)
+			}
+		}
+
+		h.Deaths = append(h.Deaths, d)
+	}
+
+	return h
+}
+
+// History puts the message delivery history to the context.
+func History() consumer.Middleware {
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) consumer.Result {
+		return next.Handle(WithDeliveryHistory(ctx, NewDeliveryHistory(msg)), msg)
+	})
+}
+
+// MaxRedeliveries puts the message delivery history to the context
+// and stops messages delivered more than limit times before from reaching the handler.
+// Such messages are passed to onExceeded, or rejected without requeue if it is nil,
+// so they are dead-lettered if the queue has a dead letter exchange.
+func MaxRedeliveries(limit int64, onExceeded consumer.Handler) consumer.Middleware {
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) consumer.Result {
+		h := NewDeliveryHistory(msg)
+		ctx = WithDeliveryHistory(ctx, h)
+
+		if h.Redeliveries() <= limit {
+			return next.Handle(ctx, msg)
+		}
+
+		log(ctx, "[WARN] message redelivered %d times, max %d", h.Redeliveries(), limit)
+
+		if onExceeded != nil {
+			return onExceeded.Handle(ctx, msg)
+		}
+
+		return consumer.Reject
+	})
+}
+
+func WithDeliveryHistory(ctx context.Context, h DeliveryHistory) context.Context {
+	return context.WithValue(ctx, deliveryHistoryKey, h)
+}
+
+func GetDeliveryHistory(ctx context.Context) (DeliveryHistory, bool) {
+	h, ok := ctx.Value(deliveryHistoryKey).(DeliveryHistory)
+
+	return h, ok
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int:
+		return int64(n)
+	default:
+		return 0
+	}
+}
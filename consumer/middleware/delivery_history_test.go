@@ -0,0 +1,141 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/consumer/middleware"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeliveryHistory(main *testing.T) {
+	main.Run("NoHeaders", func(t *testing.T) {
+		h := middleware.NewDeliveryHistory(amqp.Delivery{})
+
+		assert.Equal(t, middleware.DeliveryHistory{}, h)
+		assert.Equal(t, int64(0), h.Redeliveries())
+	})
+
+	main.Run("RedeliveredWithoutCounters", func(t *testing.T) {
+		h := middleware.NewDeliveryHistory(amqp.Delivery{Redelivered: true})
+
+		assert.Equal(t, int64(1), h.Redeliveries())
+	})
+
+	main.Run("DeliveryCountAndDeaths", func(t *testing.T) {
+		deathTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		h := middleware.NewDeliveryHistory(amqp.Delivery{
+			Redelivered: true,
+			Headers: amqp.Table{
+				"x-delivery-count": int64(2),
+				"x-death": []interface{}{
+					amqp.Table{
+						"count":        int64(3),
+						"reason":       "expired",
+						"queue":        "theQueue.retry.1000",
+						"exchange":     "",
+						"routing-keys": []interface{}{"theQueue.retry.1000"},
+						"time":         deathTime,
+					},
+					amqp.Table{
+						"count":  int64(1),
+						"reason": "rejected",
+						"queue":  "theQueue",
+					},
+					"invalid",
+				},
+			},
+		})
+
+		assert.Equal(t, middleware.DeliveryHistory{
+			DeliveryCount: 2,
+			Redelivered:   true,
+			Deaths: []middleware.Death{
+				{
+					Queue:       "theQueue.retry.1000",
+					Reason:      "expired",
+					RoutingKeys: []string{"theQueue.retry.1000"},
+					Count:       3,
+					Time:        deathTime,
+				},
+				{
+					Queue:  "theQueue",
+					Reason: "rejected",
+					Count:  1,
+				},
+			},
+		}, h)
+		assert.Equal(t, int64(3), h.Redeliveries())
+	})
+}
+
+func TestHistory(t *testing.T) {
+	handler := middleware.History()(consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) consumer.Result {
+		h, ok := middleware.GetDeliveryHistory(ctx)
+		require.True(t, ok)
+		assert.Equal(t, int64(3), h.DeliveryCount)
+
+		return consumer.Ack
+	}))
+
+	res := handler.Handle(context.Background(), amqp.Delivery{Headers: amqp.Table{"x-delivery-count": int32(3)}})
+	assert.Equal(t, consumer.Ack, res)
+}
+
+func TestMaxRedeliveries(main *testing.T) {
+	main.Run("CallHandlerIfWithinLimit", func(t *testing.T) {
+		handler := middleware.MaxRedeliveries(2, nil)(consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) consumer.Result {
+			h, ok := middleware.GetDeliveryHistory(ctx)
+			require.True(t, ok)
+			assert.Equal(t, int64(2), h.Redeliveries())
+
+			return consumer.Ack
+		}))
+
+		res := handler.Handle(context.Background(), amqp.Delivery{Headers: amqp.Table{"x-delivery-count": int64(2)}})
+		assert.Equal(t, consumer.Ack, res)
+	})
+
+	main.Run("RejectIfOverLimit", func(t *testing.T) {
+		l := &loggerStub{}
+		ctx := middleware.WithLogger(context.Background(), l)
+
+		handler := middleware.MaxRedeliveries(2, nil)(consumer.HandlerFunc(func(_ context.Context, _ amqp.Delivery) consumer.Result {
+			t.Error("handler must not be called")
+
+			return consumer.Ack
+		}))
+
+		res := handler.Handle(ctx, amqp.Delivery{Headers: amqp.Table{"x-delivery-count": int64(3)}})
+		assert.Equal(t, consumer.Reject, res)
+
+		require.Equal(t, []string{"[WARN] message redelivered %d times, max %d"}, l.Formats)
+		require.Equal(t, [][]interface{}{{int64(3), int64(2)}}, l.Args)
+	})
+
+	main.Run("CallHookIfOverLimit", func(t *testing.T) {
+		hook := consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) consumer.Result {
+			h, ok := middleware.GetDeliveryHistory(ctx)
+			require.True(t, ok)
+			assert.Len(t, h.Deaths, 1)
+
+			return consumer.Ack
+		})
+
+		handler := middleware.MaxRedeliveries(0, hook)(consumer.HandlerFunc(func(_ context.Context, _ amqp.Delivery) consumer.Result {
+			t.Error("handler must not be called")
+
+			return consumer.Nack
+		}))
+
+		res := handler.Handle(context.Background(), amqp.Delivery{Headers: amqp.Table{
+			"x-death": []interface{}{amqp.Table{"count": int64(1)}},
+		}})
+		assert.Equal(t, consumer.Ack, res)
+	})
+}
@@ -12,7 +12,7 @@ import (
 )
 
 func ExpireToTimeout(defaultTimeout time.Duration) func(next consumer.Handler) consumer.Handler {
-	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) (result interface{}) {
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) (result consumer.Result) {
 		if msg.Expiration == "" {
 			if defaultTimeout.Nanoseconds() == 0 {
 				return next.Handle(ctx, msg)
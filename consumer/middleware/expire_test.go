@@ -22,7 +22,7 @@ func TestExpireToTimeoutSetContextTimeout(t *testing.T) {
 
 	parentCtx := context.Background()
 
-	handler := middleware.ExpireToTimeout(0)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handler := middleware.ExpireToTimeout(0)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		require.NotEqual(t, parentCtx, ctx)
 
 		select {
@@ -39,7 +39,7 @@ func TestExpireToTimeoutSetContextTimeout(t *testing.T) {
 			assert.Fail(t, "context must be done")
 		}
 
-		return nil
+		return consumer.Settled
 	}))
 
 	handler.Handle(parentCtx, msg)
@@ -50,10 +50,10 @@ func TestExpireToTimeoutNoExpirationNoDefault(t *testing.T) {
 
 	parentCtx := context.Background()
 
-	handler := middleware.ExpireToTimeout(0)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handler := middleware.ExpireToTimeout(0)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		require.Same(t, parentCtx, ctx)
 
-		return nil
+		return consumer.Settled
 	}))
 
 	handler.Handle(parentCtx, msg)
@@ -64,7 +64,7 @@ func TestExpireToTimeoutNoExpirationSetDefault(t *testing.T) {
 
 	parentCtx := context.Background()
 
-	handler := middleware.ExpireToTimeout(100 * time.Millisecond)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handler := middleware.ExpireToTimeout(100 * time.Millisecond)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		require.NotEqual(t, parentCtx, ctx)
 
 		select {
@@ -81,7 +81,7 @@ func TestExpireToTimeoutNoExpirationSetDefault(t *testing.T) {
 			assert.Fail(t, "context must be done")
 		}
 
-		return nil
+		return consumer.Settled
 	}))
 
 	handler.Handle(parentCtx, msg)
@@ -92,10 +92,10 @@ func TestExpireToTimeoutExpirationInvalidAndNoDefault(t *testing.T) {
 
 	parentCtx := context.Background()
 
-	handler := middleware.ExpireToTimeout(0)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handler := middleware.ExpireToTimeout(0)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		require.Same(t, parentCtx, ctx)
 
-		return nil
+		return consumer.Settled
 	}))
 
 	handler.Handle(parentCtx, msg)
@@ -107,7 +107,7 @@ func TestExpireToTimeoutExpirationInvalidAndSetDefault(t *testing.T) {
 
 	parentCtx := context.Background()
 
-	handler := middleware.ExpireToTimeout(100 * time.Millisecond)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handler := middleware.ExpireToTimeout(100 * time.Millisecond)(consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		require.NotEqual(t, parentCtx, ctx)
 
 		select {
@@ -124,7 +124,7 @@ func TestExpireToTimeoutExpirationInvalidAndSetDefault(t *testing.T) {
 			assert.Fail(t, "context must be done")
 		}
 
-		return nil
+		return consumer.Settled
 	}))
 
 	handler.Handle(parentCtx, msg)
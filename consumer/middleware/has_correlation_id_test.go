@@ -6,6 +6,7 @@ import (
 
 	"fmt"
 
+	"github.com/makasim/amqpextra/consumer"
 	"github.com/makasim/amqpextra/consumer/middleware"
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
@@ -22,10 +23,10 @@ func TestHasCorrelationIDSet(t *testing.T) {
 
 	ctx := context.Background()
 
-	handler := middleware.HasCorrelationID()(dummyHandler(nil))
+	handler := middleware.HasCorrelationID()(dummyHandler(consumer.Settled))
 
 	res := handler.Handle(ctx, msg)
-	assert.Nil(t, res)
+	assert.Equal(t, consumer.Settled, res)
 }
 
 func TestHasCorrelationIDNotSet(t *testing.T) {
@@ -40,10 +41,10 @@ func TestHasCorrelationIDNotSet(t *testing.T) {
 	msg.Acknowledger = &a
 	ctx := context.Background()
 
-	handler := middleware.HasCorrelationID()(dummyHandler(nil))
+	handler := middleware.HasCorrelationID()(dummyHandler(consumer.Settled))
 
 	res := handler.Handle(ctx, msg)
-	assert.Nil(t, res)
+	assert.Equal(t, consumer.Settled, res)
 }
 
 func TestHasCorrelationIDNotSetWithLogger(t *testing.T) {
@@ -62,10 +63,10 @@ func TestHasCorrelationIDNotSetWithLogger(t *testing.T) {
 	ctx := context.Background()
 	ctx = middleware.WithLogger(ctx, l)
 
-	handler := middleware.HasCorrelationID()(dummyHandler(nil))
+	handler := middleware.HasCorrelationID()(dummyHandler(consumer.Settled))
 
 	res := handler.Handle(ctx, msg)
-	assert.Nil(t, res)
+	assert.Equal(t, consumer.Settled, res)
 
 	require.Len(t, l.Formats, 1)
 	assert.Equal(t, "[WARN] no correlation id", l.Formats[0])
@@ -86,10 +87,10 @@ func TestHasCorrelationIDNotSetAndNackErrored(t *testing.T) {
 
 	ctx := context.Background()
 
-	handler := middleware.HasCorrelationID()(dummyHandler(nil))
+	handler := middleware.HasCorrelationID()(dummyHandler(consumer.Settled))
 
 	res := handler.Handle(ctx, msg)
-	assert.Nil(t, res)
+	assert.Equal(t, consumer.Settled, res)
 }
 
 func TestHasCorrelationIDNotSetAndNackErroredWithLogger(t *testing.T) {
@@ -110,10 +111,10 @@ func TestHasCorrelationIDNotSetAndNackErroredWithLogger(t *testing.T) {
 	ctx := context.Background()
 	ctx = middleware.WithLogger(ctx, l)
 
-	handler := middleware.HasCorrelationID()(dummyHandler(nil))
+	handler := middleware.HasCorrelationID()(dummyHandler(consumer.Settled))
 
 	res := handler.Handle(ctx, msg)
-	assert.Nil(t, res)
+	assert.Equal(t, consumer.Settled, res)
 
 	require.Len(t, l.Formats, 2)
 	assert.Equal(t, "[WARN] no correlation id", l.Formats[0])
@@ -8,7 +8,7 @@ import (
 )
 
 func HasReplyTo() consumer.Middleware {
-	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) interface{} {
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) consumer.Result {
 		if msg.ReplyTo == "" {
 			log(ctx, "[WARN] no reply to")
 
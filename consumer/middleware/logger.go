@@ -11,7 +11,7 @@ import (
 var loggerKey = &contextKey{"logger"}
 
 func Logger(l logger.Logger) func(next consumer.Handler) consumer.Handler {
-	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) interface{} {
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) consumer.Result {
 		return next.Handle(WithLogger(ctx, l), msg)
 	})
 }
@@ -14,13 +14,13 @@ import (
 func TestLoggerMiddleware(t *testing.T) {
 	l := &loggerStub{}
 
-	handlerFunc := consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) interface{} {
+	handlerFunc := consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) consumer.Result {
 		ll, ok := middleware.GetLogger(ctx)
 
 		require.True(t, ok)
 		require.Same(t, l, ll)
 
-		return "theResult"
+		return consumer.Ack
 	})
 
 	ctx := context.Background()
@@ -28,7 +28,7 @@ func TestLoggerMiddleware(t *testing.T) {
 	handler := middleware.Logger(l)(handlerFunc)
 
 	res := handler.Handle(ctx, amqp.Delivery{})
-	assert.Equal(t, "theResult", res)
+	assert.Equal(t, consumer.Ack, res)
 }
 
 func TestLoggerWithLogger(t *testing.T) {
@@ -37,17 +37,17 @@ func TestLoggerWithLogger(t *testing.T) {
 	ctx := context.Background()
 	ctx = middleware.WithLogger(ctx, l)
 
-	handler := consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) interface{} {
+	handler := consumer.HandlerFunc(func(ctx context.Context, _ amqp.Delivery) consumer.Result {
 		ll, ok := middleware.GetLogger(ctx)
 
 		require.True(t, ok)
 		require.Same(t, l, ll)
 
-		return "theResult"
+		return consumer.Ack
 	})
 
 	res := handler.Handle(ctx, amqp.Delivery{})
-	assert.Equal(t, "theResult", res)
+	assert.Equal(t, consumer.Ack, res)
 }
 
 func TestLoggerGetLoggerNotSet(t *testing.T) {
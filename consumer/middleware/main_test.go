@@ -40,8 +40,8 @@ func (m *acknowledgerMock) Reject(tag uint64, requeue bool) error {
 	return args.Error(0)
 }
 
-func dummyHandler(result interface{}) consumer.Handler {
-	return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+func dummyHandler(result consumer.Result) consumer.Handler {
+	return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		return result
 	})
 }
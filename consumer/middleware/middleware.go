@@ -24,17 +24,17 @@ func log(ctx context.Context, format string, v ...interface{}) {
 	}
 }
 
-func nack(ctx context.Context, msg amqp.Delivery) interface{} {
+func nack(ctx context.Context, msg amqp.Delivery) consumer.Result {
 	if err := msg.Nack(false, false); err != nil {
 		log(ctx, "[ERROR] msg nack: %s", err)
 	}
 
-	return nil
+	return consumer.Settled
 }
 
-func wrap(fn func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) interface{}) func(next consumer.Handler) consumer.Handler {
+func wrap(fn func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) consumer.Result) func(next consumer.Handler) consumer.Handler {
 	return func(next consumer.Handler) consumer.Handler {
-		return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+		return consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 			return fn(ctx, msg, next)
 		})
 	}
@@ -8,7 +8,7 @@ import (
 )
 
 func Recover() consumer.Middleware {
-	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) (result interface{}) {
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) (result consumer.Result) {
 		defer func() {
 			if e := recover(); e != nil {
 				log(ctx, "[ERROR] handler panicked: %v", e)
@@ -16,6 +16,8 @@ func Recover() consumer.Middleware {
 				if nackErr := msg.Nack(false, false); nackErr != nil {
 					log(ctx, "[ERROR] msg nack: %v", e)
 				}
+
+				result = consumer.Settled
 			}
 		}()
 
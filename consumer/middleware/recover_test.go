@@ -16,11 +16,11 @@ func TestRecoverPropagateResultIfNoPanic(t *testing.T) {
 	msg.Body = []byte("original-message")
 	ctx := context.Background()
 
-	handler := middleware.Recover()(dummyHandler("theResult"))
+	handler := middleware.Recover()(dummyHandler(consumer.Ack))
 
 	reply := handler.Handle(ctx, msg)
 
-	assert.Equal(t, "theResult", reply)
+	assert.Equal(t, consumer.Ack, reply)
 }
 
 func TestRecoverNackOnPanic(t *testing.T) {
@@ -35,14 +35,14 @@ func TestRecoverNackOnPanic(t *testing.T) {
 	msg.Acknowledger = &a
 	ctx := context.Background()
 
-	handlerFunc := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handlerFunc := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		panic("a panic")
 	})
 
 	handler := middleware.Recover()(handlerFunc)
 
 	reply := handler.Handle(ctx, msg)
-	assert.Nil(t, reply)
+	assert.Equal(t, consumer.Settled, reply)
 }
 
 func TestRecoverNoPanicNoLog(t *testing.T) {
@@ -52,7 +52,7 @@ func TestRecoverNoPanicNoLog(t *testing.T) {
 	msg.Body = []byte("original-message")
 	ctx := middleware.WithLogger(context.Background(), l)
 
-	handler := middleware.Recover()(dummyHandler("aResult"))
+	handler := middleware.Recover()(dummyHandler(consumer.Ack))
 	handler.Handle(ctx, msg)
 
 	assert.Equal(t, 0, len(l.Formats))
@@ -72,14 +72,14 @@ func TestRecoverLogOnPanic(t *testing.T) {
 	msg.Acknowledger = &a
 	ctx := middleware.WithLogger(context.Background(), l)
 
-	handlerFunc := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	handlerFunc := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		panic("a panic")
 	})
 
 	handler := middleware.Recover()(handlerFunc)
 
 	reply := handler.Handle(ctx, msg)
-	assert.Nil(t, reply)
+	assert.Equal(t, consumer.Settled, reply)
 
 	require.Equal(t, 1, len(l.Formats))
 	assert.Equal(t, "[ERROR] handler panicked: %v", l.Formats[0])
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/declare"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/streadway/amqp"
+)
+
+// RetryAttemptHeader holds how many times the message has been retried.
+const RetryAttemptHeader = "x-retry-attempt"
+
+// RetryErrorHeader holds the error of the last failed attempt.
+const RetryErrorHeader = "x-retry-error"
+
+// Retry republishes messages the handler failed with consumer.Error or asked to consumer.RetryAfter
+// to a retry queue declared by declare.RetryQueues, and acks the original once the publisher confirms.
+// The message comes back to queue after the retry queue delay.
+// The n-th attempt waits delays[n-1], the last delay is used for the rest of attempts.
+// Delays are expected in ascending order.
+// A consumer.RetryAfter result waits the shortest delay not less than the asked one, or the last delay.
+// After maxAttempts the message is moved to the parking lot queue.
+// The publisher should be in confirmation mode, otherwise the original could be acked before the copy is stored.
+// Attempts are counted by the RetryAttemptHeader only. The expiry from a retry queue adds an x-death entry too,
+// but DeliveryHistory does not count expiries, so MaxRedeliveries could be used in front of Retry
+// to catch messages that are requeued or rejected over and over without counting the retries again.
+func Retry(p *publisher.Publisher, queue string, maxAttempts int, delays ...time.Duration) consumer.Middleware {
+	if p == nil {
+		panic("publisher must be not nil")
+	}
+	if maxAttempts < 1 {
+		panic("max attempts must be greater than zero")
+	}
+	if len(delays) == 0 {
+		panic("at least one delay must be set")
+	}
+	for _, delay := range delays {
+		if delay.Milliseconds() < 1 {
+			panic("retry delay must be at least 1ms")
+		}
+	}
+
+	return wrap(func(ctx context.Context, msg amqp.Delivery, next consumer.Handler) consumer.Result {
+		result := next.Handle(ctx, msg)
+
+		var delay time.Duration
+		var errText string
+		attempt := retryAttempt(msg) + 1
+		retryDelay, isRetryAfter := result.RetryDelay()
+		switch {
+		case result.Err() != nil:
+			errText = result.Err().Error()
+			delay = delays[len(delays)-1]
+			if attempt <= len(delays) {
+				delay = delays[attempt-1]
+			}
+		case isRetryAfter:
+			delay = delays[len(delays)-1]
+			for _, d := range delays {
+				if d >= retryDelay && d < delay {
+					delay = d
+				}
+			}
+		default:
+			return result
+		}
+
+		if errText != "" {
+			log(ctx, "[ERROR] handler: %s", errText)
+		}
+
+		publishing := deliveryToPublishing(msg)
+		publishing.Headers[RetryAttemptHeader] = int32(attempt)
+		if errText != "" {
+			publishing.Headers[RetryErrorHeader] = errText
+		}
+
+		key := declare.RetryQueueName(queue, delay)
+		if attempt > maxAttempts {
+			key = declare.ParkingLotQueueName(queue)
+		}
+
+		if err := p.Publish(publisher.Message{
+			Context:    ctx,
+			Key:        key,
+			Publishing: publishing,
+		}); err != nil {
+			log(ctx, "[ERROR] retry: publish to %s: %s", key, err)
+
+			return consumer.RetryAfter(delay)
+		}
+
+		if attempt > maxAttempts {
+			log(ctx, "[WARN] retry: message parked after %d attempts", maxAttempts)
+		}
+
+		return consumer.Ack
+	})
+}
+
+func retryAttempt(msg amqp.Delivery) int {
+	return int(toInt64(msg.Headers[RetryAttemptHeader]))
+}
+
+func deliveryToPublishing(msg amqp.Delivery) amqp.Publishing {
+	headers := make(amqp.Table, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	}
+}
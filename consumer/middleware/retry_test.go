@@ -0,0 +1,264 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/consumer/middleware"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/makasim/amqpextra/publisher/mock_publisher"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+type published struct {
+	key string
+	msg amqp.Publishing
+}
+
+func TestRetry(main *testing.T) {
+	delays := []time.Duration{time.Second, time.Second * 10, time.Minute}
+
+	main.Run("FirstAttemptOnError", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, publishedCh := newRetryPublisher(ctrl, nil)
+		defer closePublisher(p)
+
+		l := &loggerStub{}
+		ctx := middleware.WithLogger(context.Background(), l)
+
+		handler := middleware.Retry(p, "theQueue", 3, delays...)(dummyHandler(consumer.Error(fmt.Errorf("the error"))))
+
+		res := handler.Handle(ctx, amqp.Delivery{
+			Headers:       amqp.Table{"foo": "fooVal"},
+			CorrelationId: "theID",
+			Body:          []byte("theBody"),
+		})
+		assert.Equal(t, consumer.Ack, res)
+
+		pub := <-publishedCh
+		assert.Equal(t, "theQueue.retry.1000", pub.key)
+		assert.Equal(t, "theID", pub.msg.CorrelationId)
+		assert.Equal(t, "theBody", string(pub.msg.Body))
+		assert.Equal(t, amqp.Table{
+			"foo":                         "fooVal",
+			middleware.RetryAttemptHeader: int32(1),
+			middleware.RetryErrorHeader:   "the error",
+		}, pub.msg.Headers)
+
+		require.Equal(t, []string{"[ERROR] handler: %s"}, l.Formats)
+	})
+
+	main.Run("NextAttemptUsesNextDelay", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, publishedCh := newRetryPublisher(ctrl, nil)
+		defer closePublisher(p)
+
+		handler := middleware.Retry(p, "theQueue", 5, delays...)(dummyHandler(consumer.Error(fmt.Errorf("the error"))))
+
+		res := handler.Handle(context.Background(), amqp.Delivery{
+			Headers: amqp.Table{middleware.RetryAttemptHeader: int32(1)},
+		})
+		assert.Equal(t, consumer.Ack, res)
+
+		pub := <-publishedCh
+		assert.Equal(t, "theQueue.retry.10000", pub.key)
+		assert.Equal(t, int32(2), pub.msg.Headers[middleware.RetryAttemptHeader])
+
+		res = handler.Handle(context.Background(), amqp.Delivery{
+			Headers: amqp.Table{middleware.RetryAttemptHeader: int64(3)},
+		})
+		assert.Equal(t, consumer.Ack, res)
+
+		pub = <-publishedCh
+		assert.Equal(t, "theQueue.retry.60000", pub.key)
+		assert.Equal(t, int32(4), pub.msg.Headers[middleware.RetryAttemptHeader])
+	})
+
+	main.Run("RetryAfterPicksDelay", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, publishedCh := newRetryPublisher(ctrl, nil)
+		defer closePublisher(p)
+
+		handler := middleware.Retry(p, "theQueue", 3, delays...)(dummyHandler(consumer.RetryAfter(time.Second * 5)))
+
+		res := handler.Handle(context.Background(), amqp.Delivery{})
+		assert.Equal(t, consumer.Ack, res)
+
+		pub := <-publishedCh
+		assert.Equal(t, "theQueue.retry.10000", pub.key)
+		assert.Equal(t, amqp.Table{middleware.RetryAttemptHeader: int32(1)}, pub.msg.Headers)
+	})
+
+	main.Run("ParkAfterMaxAttempts", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, publishedCh := newRetryPublisher(ctrl, nil)
+		defer closePublisher(p)
+
+		handler := middleware.Retry(p, "theQueue", 3, delays...)(dummyHandler(consumer.Error(fmt.Errorf("the error"))))
+
+		res := handler.Handle(context.Background(), amqp.Delivery{
+			Headers: amqp.Table{middleware.RetryAttemptHeader: int32(3)},
+		})
+		assert.Equal(t, consumer.Ack, res)
+
+		pub := <-publishedCh
+		assert.Equal(t, "theQueue.parking-lot", pub.key)
+		assert.Equal(t, int32(4), pub.msg.Headers[middleware.RetryAttemptHeader])
+	})
+
+	main.Run("PassOtherResults", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, _ := newRetryPublisher(ctrl, nil)
+		defer closePublisher(p)
+
+		for _, expected := range []consumer.Result{consumer.Settled, consumer.Ack, consumer.Nack, consumer.Requeue, consumer.Reject} {
+			handler := middleware.Retry(p, "theQueue", 3, delays...)(dummyHandler(expected))
+
+			assert.Equal(t, expected, handler.Handle(context.Background(), amqp.Delivery{}))
+		}
+	})
+
+	main.Run("RetryAfterIfPublishFailed", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, _ := newRetryPublisher(ctrl, fmt.Errorf("publish error"))
+		defer closePublisher(p)
+
+		l := &loggerStub{}
+		ctx := middleware.WithLogger(context.Background(), l)
+
+		handler := middleware.Retry(p, "theQueue", 3, delays...)(dummyHandler(consumer.RetryAfter(time.Second)))
+
+		res := handler.Handle(ctx, amqp.Delivery{})
+		assert.Equal(t, consumer.RetryAfter(time.Second), res)
+
+		require.Equal(t, []string{"[ERROR] retry: publish to %s: %s"}, l.Formats)
+	})
+
+	main.Run("NotCountedTwiceWithMaxRedeliveries", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		p, publishedCh := newRetryPublisher(ctrl, nil)
+		defer closePublisher(p)
+
+		handler := consumer.Wrap(
+			dummyHandler(consumer.Error(fmt.Errorf("the error"))),
+			middleware.MaxRedeliveries(1, nil),
+			middleware.Retry(p, "theQueue", 3, delays...),
+		)
+
+		res := handler.Handle(context.Background(), amqp.Delivery{
+			Headers: amqp.Table{
+				middleware.RetryAttemptHeader: int32(2),
+				"x-death": []interface{}{
+					amqp.Table{"count": int64(1), "reason": "expired", "queue": "theQueue.retry.1000"},
+					amqp.Table{"count": int64(1), "reason": "expired", "queue": "theQueue.retry.10000"},
+				},
+			},
+		})
+		assert.Equal(t, consumer.Ack, res)
+
+		pub := <-publishedCh
+		assert.Equal(t, "theQueue.retry.60000", pub.key)
+		assert.Equal(t, int32(3), pub.msg.Headers[middleware.RetryAttemptHeader])
+	})
+
+	main.Run("PanicIfDelayLessThanMillisecond", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection))
+		require.NoError(t, err)
+		defer closePublisher(p)
+
+		require.PanicsWithValue(t, "retry delay must be at least 1ms", func() {
+			middleware.Retry(p, "theQueue", 3, time.Second, 0)
+		})
+		require.PanicsWithValue(t, "retry delay must be at least 1ms", func() {
+			middleware.Retry(p, "theQueue", 3, time.Microsecond)
+		})
+	})
+
+	main.Run("PanicIfNoDelays", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection))
+		require.NoError(t, err)
+		defer closePublisher(p)
+
+		require.PanicsWithValue(t, "at least one delay must be set", func() {
+			middleware.Retry(p, "theQueue", 3)
+		})
+	})
+}
+
+func newRetryPublisher(ctrl *gomock.Controller, publishErr error) (*publisher.Publisher, <-chan published) {
+	publishedCh := make(chan published, 10)
+
+	ch := mock_publisher.NewMockAMQPChannel(ctrl)
+	ch.EXPECT().NotifyReturn(gomock.Any()).AnyTimes()
+	ch.EXPECT().NotifyClose(gomock.Any()).AnyTimes()
+	ch.EXPECT().NotifyFlow(gomock.Any()).AnyTimes()
+	ch.EXPECT().
+		Publish("", gomock.Any(), false, false, gomock.Any()).
+		DoAndReturn(func(_, key string, _, _ bool, msg amqp.Publishing) error {
+			if publishErr != nil {
+				return publishErr
+			}
+
+			publishedCh <- published{key: key, msg: msg}
+
+			return nil
+		}).
+		AnyTimes()
+	ch.EXPECT().Close().AnyTimes()
+
+	connCh := make(chan *publisher.Connection, 1)
+	connCh <- publisher.NewConnection(mock_publisher.NewMockAMQPConnection(ctrl), nil)
+
+	p, err := publisher.New(connCh, publisher.WithInitFunc(func(_ publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+		return ch, nil
+	}))
+	if err != nil {
+		panic(err)
+	}
+
+	return p, publishedCh
+}
+
+func closePublisher(p *publisher.Publisher) {
+	p.Close()
+	<-p.NotifyClosed()
+}
@@ -0,0 +1,141 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/makasim/amqpextra/logger"
+	"github.com/streadway/amqp"
+)
+
+// Result is returned by a handler and tells the worker how to settle the message.
+// It is a value so a handler could not return none, use one of Ack, Nack, Requeue, Reject, Settled, RetryAfter or Error.
+// A handler which settles the message itself, or hands it over to be settled later, returns Settled.
+// The zero Result is a handler bug, the worker logs an error and nacks the message without requeue.
+type Result struct {
+	action action
+	delay  time.Duration
+	err    error
+}
+
+type action int
+
+const (
+	noAction action = iota
+	settledAction
+	ackAction
+	nackAction
+	requeueAction
+	rejectAction
+	retryAfterAction
+	errorAction
+)
+
+var (
+	// Settled tells the worker the message has been settled by the handler, the worker does nothing.
+	Settled = Result{action: settledAction}
+	// Ack acks the message.
+	Ack = Result{action: ackAction}
+	// Nack nacks the message without requeue, it is dead-lettered if the queue has a dead letter exchange.
+	Nack = Result{action: nackAction}
+	// Requeue nacks the message and puts it back to the queue.
+	Requeue = Result{action: requeueAction}
+	// Reject rejects the message without requeue.
+	Reject = Result{action: rejectAction}
+)
+
+// RetryAfter asks to deliver the message again once delay passed.
+// Without a retry middleware the message is kept unacked for delay and requeued after that.
+// Meanwhile it holds a prefetch slot, and if the channel is closed the server requeues it at once,
+// so the delay is not kept. Use middleware.Retry with declare.RetryQueues for a delay that survives reconnects.
+func RetryAfter(delay time.Duration) Result {
+	return Result{action: retryAfterAction, delay: delay}
+}
+
+// Error reports the handler failed with err.
+// The worker logs the error and nacks the message without requeue.
+func Error(err error) Result {
+	if err == nil {
+		panic("error must be not nil")
+	}
+
+	return Result{action: errorAction, err: err}
+}
+
+// Err returns the error of a result created by Error, and nil for other results.
+func (r Result) Err() error {
+	return r.err
+}
+
+// RetryDelay returns the delay of a result created by RetryAfter, ok is false for other results.
+func (r Result) RetryDelay() (delay time.Duration, ok bool) {
+	return r.delay, r.action == retryAfterAction
+}
+
+// Settle settles msg according to the result.
+func (r Result) Settle(msg amqp.Delivery) error {
+	switch r.action {
+	case settledAction:
+		return nil
+	case ackAction:
+		return msg.Ack(false)
+	case requeueAction:
+		return msg.Nack(false, true)
+	case rejectAction:
+		return msg.Reject(false)
+	case retryAfterAction:
+		if r.delay <= 0 {
+			return msg.Nack(false, true)
+		}
+
+		time.AfterFunc(r.delay, func() {
+			_ = msg.Nack(false, true)
+		})
+
+		return nil
+	default:
+		return msg.Nack(false, false)
+	}
+}
+
+func (r Result) String() string {
+	switch r.action {
+	case settledAction:
+		return "settled"
+	case ackAction:
+		return "ack"
+	case nackAction:
+		return "nack"
+	case requeueAction:
+		return "requeue"
+	case rejectAction:
+		return "reject"
+	case retryAfterAction:
+		return fmt.Sprintf("retry after %s", r.delay)
+	case errorAction:
+		return fmt.Sprintf("error: %s", r.err)
+	default:
+		return "empty"
+	}
+}
+
+// Settle settles msg according to the handler result, errors are logged.
+// Workers call it for every handled message.
+func Settle(l logger.Logger, msg amqp.Delivery, res Result) {
+	switch res.action {
+	case noAction:
+		l.Printf("[ERROR] handler returned an empty result, the message is nacked")
+	case errorAction:
+		l.Printf("[ERROR] handler: %v", res.err)
+	}
+
+	if err := res.Settle(msg); err != nil {
+		l.Printf("[ERROR] settle message: %s", err)
+	}
+}
+
+// handle runs the handler and settles msg according to its result.
+func handle(ctx context.Context, l logger.Logger, h Handler, msg amqp.Delivery) {
+	Settle(l, msg, h.Handle(ctx, msg))
+}
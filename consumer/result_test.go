@@ -0,0 +1,123 @@
+package consumer_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/logger"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettle(main *testing.T) {
+	main.Run("Results", func(t *testing.T) {
+		cases := []struct {
+			result   consumer.Result
+			expected string
+		}{
+			{consumer.Ack, "ack 1 false"},
+			{consumer.Nack, "nack 1 false false"},
+			{consumer.Requeue, "nack 1 false true"},
+			{consumer.Reject, "reject 1 false"},
+			{consumer.RetryAfter(0), "nack 1 false true"},
+		}
+
+		for _, c := range cases {
+			a := newAcknowledgerStub()
+
+			l := logger.NewTest()
+			consumer.Settle(l, amqp.Delivery{Acknowledger: a, DeliveryTag: 1}, c.result)
+
+			require.Equal(t, []string{c.expected}, a.calls(), c.result)
+			require.Equal(t, "", l.Logs())
+		}
+	})
+
+	main.Run("EmptyResultLoggedAndNacked", func(t *testing.T) {
+		a := newAcknowledgerStub()
+
+		l := logger.NewTest()
+		consumer.Settle(l, amqp.Delivery{Acknowledger: a, DeliveryTag: 1}, consumer.Result{})
+
+		require.Equal(t, []string{"nack 1 false false"}, a.calls())
+		require.Equal(t, "[ERROR] handler returned an empty result, the message is nacked\n", l.Logs())
+	})
+
+	main.Run("SettledResult", func(t *testing.T) {
+		a := newAcknowledgerStub()
+
+		l := logger.NewTest()
+		consumer.Settle(l, amqp.Delivery{Acknowledger: a}, consumer.Settled)
+
+		require.Empty(t, a.calls())
+		require.Equal(t, "", l.Logs())
+	})
+
+	main.Run("ErrorLoggedAndNacked", func(t *testing.T) {
+		a := newAcknowledgerStub()
+
+		l := logger.NewTest()
+		consumer.Settle(l, amqp.Delivery{Acknowledger: a, DeliveryTag: 1}, consumer.Error(fmt.Errorf("the error")))
+
+		require.Equal(t, []string{"nack 1 false false"}, a.calls())
+		require.Equal(t, "[ERROR] handler: the error\n", l.Logs())
+	})
+
+	main.Run("RetryAfterRequeuedOnceDelayPassed", func(t *testing.T) {
+		a := newAcknowledgerStub()
+
+		l := logger.NewTest()
+		consumer.Settle(l, amqp.Delivery{Acknowledger: a, DeliveryTag: 1}, consumer.RetryAfter(time.Millisecond*50))
+
+		require.Empty(t, a.calls())
+		time.Sleep(time.Millisecond * 100)
+		require.Equal(t, []string{"nack 1 false true"}, a.calls())
+	})
+
+	main.Run("SettleErrorLogged", func(t *testing.T) {
+		l := logger.NewTest()
+		consumer.Settle(l, amqp.Delivery{}, consumer.Ack)
+
+		require.Equal(t, "[ERROR] settle message: delivery not initialized\n", l.Logs())
+	})
+}
+
+type acknowledgerStub struct {
+	callCh chan string
+}
+
+func newAcknowledgerStub() *acknowledgerStub {
+	return &acknowledgerStub{callCh: make(chan string, 10)}
+}
+
+func (a *acknowledgerStub) Ack(tag uint64, multiple bool) error {
+	a.callCh <- fmt.Sprintf("ack %d %v", tag, multiple)
+
+	return nil
+}
+
+func (a *acknowledgerStub) Nack(tag uint64, multiple, requeue bool) error {
+	a.callCh <- fmt.Sprintf("nack %d %v %v", tag, multiple, requeue)
+
+	return nil
+}
+
+func (a *acknowledgerStub) Reject(tag uint64, requeue bool) error {
+	a.callCh <- fmt.Sprintf("reject %d %v", tag, requeue)
+
+	return nil
+}
+
+func (a *acknowledgerStub) calls() []string {
+	var calls []string
+	for {
+		select {
+		case call := <-a.callCh:
+			calls = append(calls, call)
+		default:
+			return calls
+		}
+	}
+}
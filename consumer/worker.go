@@ -2,6 +2,8 @@ package consumer
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
 	"sync"
 
 	"github.com/makasim/amqpextra/logger"
@@ -27,9 +29,7 @@ func (dw *DefaultWorker) Serve(ctx context.Context, h Handler, msgCh <-chan amqp
 				return
 			}
 
-			if res := h.Handle(ctx, msg); res != nil {
-				dw.Logger.Printf("[ERROR] handler return non nil result: %#v", res)
-			}
+			handle(ctx, dw.Logger, h, msg)
 		case <-ctx.Done():
 			return
 		}
@@ -70,9 +70,7 @@ func (pw *ParallelWorker) Serve(ctx context.Context, h Handler, msgCh <-chan amq
 						return
 					}
 
-					if res := h.Handle(ctx, msg); res != nil {
-						pw.Logger.Printf("[ERROR] handler return non nil result: %#v", res)
-					}
+					handle(ctx, pw.Logger, h, msg)
 				case <-ctx.Done():
 					return
 				}
@@ -82,3 +80,104 @@ func (pw *ParallelWorker) Serve(ctx context.Context, h Handler, msgCh <-chan amq
 
 	wg.Wait()
 }
+
+// KeyedWorker runs Num goroutines and sends all messages with the same key to the same goroutine,
+// so messages of one key are handled in order while different keys are handled in parallel.
+type KeyedWorker struct {
+	Num    int
+	Key    func(msg amqp.Delivery) string
+	Logger logger.Logger
+}
+
+func NewKeyedWorker(num int, key func(msg amqp.Delivery) string) *KeyedWorker {
+	if num < 1 {
+		panic("num workers must be greater than zero")
+	}
+	if key == nil {
+		panic("key func must be not nil")
+	}
+
+	return &KeyedWorker{
+		Num:    num,
+		Key:    key,
+		Logger: logger.Discard,
+	}
+}
+
+// HeaderKey takes the key from the header, messages without it share the empty key.
+func HeaderKey(header string) func(msg amqp.Delivery) string {
+	return func(msg amqp.Delivery) string {
+		if v, ok := msg.Headers[header]; ok {
+			return fmt.Sprint(v)
+		}
+
+		return ""
+	}
+}
+
+// RoutingKey takes the message routing key as the key.
+func RoutingKey(msg amqp.Delivery) string {
+	return msg.RoutingKey
+}
+
+func (kw *KeyedWorker) Serve(ctx context.Context, h Handler, msgCh <-chan amqp.Delivery) {
+	defer kw.Logger.Printf("[DEBUG] worker stopped")
+
+	kw.Logger.Printf("[DEBUG] worker starting")
+
+	wg := &sync.WaitGroup{}
+	workerChs := make([]chan amqp.Delivery, kw.Num)
+	for i := range workerChs {
+		workerCh := make(chan amqp.Delivery)
+		workerChs[i] = workerCh
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case msg, ok := <-workerCh:
+					if !ok {
+						return
+					}
+
+					handle(ctx, kw.Logger, h, msg)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	defer wg.Wait()
+	defer func() {
+		for _, workerCh := range workerChs {
+			close(workerCh)
+		}
+	}()
+
+	for {
+		select {
+		case msg, ok := <-msgCh:
+			if !ok {
+				return
+			}
+
+			select {
+			case workerChs[kw.index(msg)] <- msg:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (kw *KeyedWorker) index(msg amqp.Delivery) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(kw.Key(msg)))
+
+	return int(hash.Sum32() % uint32(kw.Num))
+}
@@ -1,7 +1,13 @@
 package consumer_test
 
 import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"context"
 
@@ -19,9 +25,9 @@ func TestDefaultWorkerProcessSomeMessages(t *testing.T) {
 
 	w := consumer.DefaultWorker{Logger: l}
 
-	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) interface{} {
+	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
 		l.Printf("[TEST] handler: %s", msg.Body)
-		return nil
+		return consumer.Settled
 	})
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
@@ -50,16 +56,16 @@ func TestDefaultWorkerProcessSomeMessages(t *testing.T) {
 `, l.Logs())
 }
 
-func TestDefaultWorkerHandlerReturnNotNil(t *testing.T) {
+func TestDefaultWorkerHandlerReturnError(t *testing.T) {
 	goleak.VerifyNone(t)
 
 	l := logger.NewTest()
 
 	w := consumer.DefaultWorker{Logger: l}
 
-	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) interface{} {
+	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
 		l.Printf("[TEST] handler: %s", msg.Body)
-		return "someValue"
+		return consumer.Error(fmt.Errorf("the error"))
 	})
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
@@ -80,11 +86,69 @@ func TestDefaultWorkerHandlerReturnNotNil(t *testing.T) {
 
 	require.Equal(t, `[DEBUG] worker starting
 [TEST] handler: first
-[ERROR] handler return non nil result: "someValue"
+[ERROR] handler: the error
+[ERROR] settle message: delivery not initialized
 [DEBUG] worker stopped
 `, l.Logs())
 }
 
+func TestDefaultWorkerEmptyResult(main *testing.T) {
+	main.Run("ErrorIfEmpty", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		l := logger.NewTest()
+
+		h := consumer.HandlerFunc(func(_ context.Context, _ amqp.Delivery) consumer.Result {
+			return consumer.Result{}
+		})
+
+		serveDefaultWorker(l, h, amqp.Delivery{Acknowledger: a, DeliveryTag: 1})
+
+		require.Equal(t, []string{"nack 1 false false"}, a.calls())
+		require.Contains(t, l.Logs(), "[ERROR] handler returned an empty result, the message is nacked\n")
+	})
+
+	main.Run("SettledResult", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		a := newAcknowledgerStub()
+		l := logger.NewTest()
+
+		h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
+			require.NoError(t, msg.Ack(false))
+
+			return consumer.Settled
+		})
+
+		serveDefaultWorker(l, h, amqp.Delivery{Acknowledger: a, DeliveryTag: 1})
+
+		require.Equal(t, []string{"ack 1 false"}, a.calls())
+		require.NotContains(t, l.Logs(), "[ERROR]")
+	})
+}
+
+func serveDefaultWorker(l logger.Logger, h consumer.Handler, msgs ...amqp.Delivery) {
+	w := consumer.DefaultWorker{Logger: l}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	doneCh := make(chan struct{})
+
+	msgCh := make(chan amqp.Delivery)
+	go func() {
+		defer close(doneCh)
+		w.Serve(ctx, h, msgCh)
+	}()
+
+	for _, msg := range msgs {
+		msgCh <- msg
+	}
+	close(msgCh)
+	<-doneCh
+}
+
 func TestParallelWorkerProcessSomeMessages(t *testing.T) {
 	goleak.VerifyNone(t)
 
@@ -93,9 +157,9 @@ func TestParallelWorkerProcessSomeMessages(t *testing.T) {
 	w := consumer.NewParallelWorker(5)
 	w.Logger = l
 
-	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) interface{} {
+	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
 		l.Printf("[TEST] handler: %s", msg.Body)
-		return nil
+		return consumer.Settled
 	})
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
@@ -124,7 +188,7 @@ func TestParallelWorkerProcessSomeMessages(t *testing.T) {
 `, l.Logs())
 }
 
-func TestParallelWorkerHandlerReturnNotNil(t *testing.T) {
+func TestParallelWorkerHandlerReturnError(t *testing.T) {
 	goleak.VerifyNone(t)
 
 	l := logger.NewTest()
@@ -132,9 +196,9 @@ func TestParallelWorkerHandlerReturnNotNil(t *testing.T) {
 	w := consumer.NewParallelWorker(5)
 	w.Logger = l
 
-	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) interface{} {
+	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
 		l.Printf("[TEST] handler: %s", msg.Body)
-		return "someValue"
+		return consumer.Error(fmt.Errorf("the error"))
 	})
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
@@ -155,7 +219,100 @@ func TestParallelWorkerHandlerReturnNotNil(t *testing.T) {
 
 	require.Equal(t, `[DEBUG] worker starting
 [TEST] handler: first
-[ERROR] handler return non nil result: "someValue"
+[ERROR] handler: the error
+[ERROR] settle message: delivery not initialized
+[DEBUG] worker stopped
+`, l.Logs())
+}
+
+func TestKeyedWorkerKeepsOrderPerKey(t *testing.T) {
+	goleak.VerifyNone(t)
+
+	w := consumer.NewKeyedWorker(4, consumer.HeaderKey("key"))
+
+	mu := &sync.Mutex{}
+	handled := make(map[string][]int)
+	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+		key := msg.Headers["key"].(string)
+		n, _ := strconv.Atoi(string(msg.Body))
+
+		mu.Lock()
+		handled[key] = append(handled[key], n)
+		mu.Unlock()
+
+		return consumer.Settled
+	})
+
+	msgCh := make(chan amqp.Delivery)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		w.Serve(context.Background(), h, msgCh)
+	}()
+
+	keys := []string{"foo", "bar", "baz"}
+	for i := 0; i < 30; i++ {
+		msgCh <- amqp.Delivery{
+			Headers: amqp.Table{"key": keys[i%len(keys)]},
+			Body:    []byte(strconv.Itoa(i)),
+		}
+	}
+
+	close(msgCh)
+	<-doneCh
+
+	require.Len(t, handled, 3)
+	for key, ns := range handled {
+		require.Len(t, ns, 10, key)
+		require.True(t, sort.IntsAreSorted(ns), "%s: %v", key, ns)
+	}
+}
+
+func TestKeyedWorkerProcessSomeMessages(t *testing.T) {
+	goleak.VerifyNone(t)
+
+	l := logger.NewTest()
+
+	w := consumer.NewKeyedWorker(5, consumer.RoutingKey)
+	w.Logger = l
+
+	h := consumer.HandlerFunc(func(_ context.Context, msg amqp.Delivery) consumer.Result {
+		l.Printf("[TEST] handler: %s", msg.Body)
+		return consumer.Settled
+	})
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	doneCh := make(chan struct{})
+
+	msgCh := make(chan amqp.Delivery)
+	go func() {
+		defer close(doneCh)
+		w.Serve(ctx, h, msgCh)
+	}()
+
+	msgCh <- amqp.Delivery{RoutingKey: "theKey", Body: []byte("first")}
+	msgCh <- amqp.Delivery{RoutingKey: "theKey", Body: []byte("second")}
+	msgCh <- amqp.Delivery{RoutingKey: "theKey", Body: []byte("third")}
+	time.Sleep(time.Millisecond * 10)
+
+	cancelFunc()
+	<-doneCh
+
+	require.Equal(t, `[DEBUG] worker starting
+[TEST] handler: first
+[TEST] handler: second
+[TEST] handler: third
 [DEBUG] worker stopped
 `, l.Logs())
 }
+
+func TestHeaderKey(t *testing.T) {
+	key := consumer.HeaderKey("aggregate-id")
+
+	require.Equal(t, "123", key(amqp.Delivery{Headers: amqp.Table{"aggregate-id": int64(123)}}))
+	require.Equal(t, "", key(amqp.Delivery{}))
+}
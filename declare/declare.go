@@ -2,8 +2,7 @@ package declare
 
 import (
 	"context"
-
-	"log"
+	"fmt"
 
 	"github.com/makasim/amqpextra"
 	"github.com/streadway/amqp"
@@ -35,25 +34,182 @@ func Queue(
 	noWait bool,
 	args amqp.Table,
 ) (amqp.Queue, error) {
-	conn, err := c.Connection(ctx)
+	var q amqp.Queue
+	err := withChannel(ctx, c, func(ch AMQPChannel) error {
+		var err error
+		q, err = ch.QueueDeclare(name, durable, autDelete, exclusive, noWait, args)
+		return err
+	})
 	if err != nil {
 		return amqp.Queue{}, err
 	}
 
-	ch, err := conn.Channel()
+	return q, nil
+}
+
+// QueueInspect returns the state of an existing queue using a passive declare.
+// It fails with NOT_FOUND if the queue does not exist.
+func QueueInspect(ctx context.Context, c *amqpextra.Dialer, name string) (amqp.Queue, error) {
+	var q amqp.Queue
+	err := withChannel(ctx, c, func(ch AMQPChannel) error {
+		var err error
+		q, err = ch.QueueDeclarePassive(name, false, false, false, false, nil)
+		return err
+	})
 	if err != nil {
 		return amqp.Queue{}, err
 	}
-	defer func() {
-		if closeErr := ch.Close(); closeErr != nil {
-			log.Print("amqpextra: declare queue: ch close: %w", closeErr)
-		}
-	}()
 
-	q, err := ch.QueueDeclare(name, durable, autDelete, exclusive, noWait, args)
+	return q, nil
+}
+
+// QueueBind binds the queue to the exchange with the key.
+func QueueBind(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	name,
+	key,
+	exchange string,
+	noWait bool,
+	args amqp.Table,
+) error {
+	return withChannel(ctx, c, func(ch AMQPChannel) error {
+		return ch.QueueBind(name, key, exchange, noWait, args)
+	})
+}
+
+// QueueUnbind removes the binding of the queue to the exchange with the key and args.
+func QueueUnbind(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	name,
+	key,
+	exchange string,
+	args amqp.Table,
+) error {
+	return withChannel(ctx, c, func(ch AMQPChannel) error {
+		return ch.QueueUnbind(name, key, exchange, args)
+	})
+}
+
+// QueuePurge removes all the messages from the queue not awaiting an ack and returns the number of purged messages.
+func QueuePurge(ctx context.Context, c *amqpextra.Dialer, name string, noWait bool) (int, error) {
+	var purged int
+	err := withChannel(ctx, c, func(ch AMQPChannel) error {
+		var err error
+		purged, err = ch.QueuePurge(name, noWait)
+		return err
+	})
 	if err != nil {
-		return amqp.Queue{}, err
+		return 0, err
 	}
 
-	return q, nil
+	return purged, nil
+}
+
+// QueueDelete deletes the queue and returns the number of messages deleted with it.
+func QueueDelete(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	name string,
+	ifUnused,
+	ifEmpty,
+	noWait bool,
+) (int, error) {
+	var deleted int
+	err := withChannel(ctx, c, func(ch AMQPChannel) error {
+		var err error
+		deleted, err = ch.QueueDelete(name, ifUnused, ifEmpty, noWait)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// Exchange declares an exchange.
+func Exchange(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	name,
+	kind string,
+	durable,
+	autoDelete,
+	internal,
+	noWait bool,
+	args amqp.Table,
+) error {
+	return withChannel(ctx, c, func(ch AMQPChannel) error {
+		return ch.ExchangeDeclare(name, kind, durable, autoDelete, internal, noWait, args)
+	})
+}
+
+// ExchangeInspect checks the exchange exists using a passive declare.
+// It fails with NOT_FOUND if the exchange does not exist.
+func ExchangeInspect(ctx context.Context, c *amqpextra.Dialer, name string) error {
+	return withChannel(ctx, c, func(ch AMQPChannel) error {
+		return ch.ExchangeDeclarePassive(name, amqp.ExchangeDirect, false, false, false, false, nil)
+	})
+}
+
+// ExchangeBind binds the destination exchange to the source exchange with the key.
+func ExchangeBind(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	destination,
+	key,
+	source string,
+	noWait bool,
+	args amqp.Table,
+) error {
+	return withChannel(ctx, c, func(ch AMQPChannel) error {
+		return ch.ExchangeBind(destination, key, source, noWait, args)
+	})
+}
+
+// ExchangeDelete deletes the exchange.
+func ExchangeDelete(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	name string,
+	ifUnused,
+	noWait bool,
+) error {
+	return withChannel(ctx, c, func(ch AMQPChannel) error {
+		return ch.ExchangeDelete(name, ifUnused, noWait)
+	})
+}
+
+// withChannel borrows a connection from the Dialer and runs fn on a new channel, see onChannel.
+func withChannel(ctx context.Context, c *amqpextra.Dialer, fn func(ch AMQPChannel) error) error {
+	conn, err := c.Connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	return onChannel(func() (AMQPChannel, error) {
+		return conn.Channel()
+	}, fn)
+}
+
+// onChannel opens a channel, runs fn and closes the channel.
+// If fn fails, the close error is dropped, the broker has likely closed the channel already.
+func onChannel(openCh func() (AMQPChannel, error), fn func(ch AMQPChannel) error) error {
+	ch, err := openCh()
+	if err != nil {
+		return fmt.Errorf("open channel: %v", err)
+	}
+
+	if err := fn(ch); err != nil {
+		_ = ch.Close()
+		return err
+	}
+
+	if err := ch.Close(); err != nil {
+		return fmt.Errorf("close channel: %v", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,81 @@
+package declare_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/makasim/amqpextra/declare"
+	"github.com/makasim/amqpextra/declare/mock_declare"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnChannel(main *testing.T) {
+	main.Run("CloseChannel", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().QueuePurge("theQueue", false).Return(10, nil),
+			ch.EXPECT().Close().Return(nil),
+		)
+
+		err := declare.OnChannel(func() (declare.AMQPChannel, error) {
+			return ch, nil
+		}, func(ch declare.AMQPChannel) error {
+			_, err := ch.QueuePurge("theQueue", false)
+			return err
+		})
+		require.NoError(t, err)
+	})
+
+	main.Run("OpenChannelErrored", func(t *testing.T) {
+		err := declare.OnChannel(func() (declare.AMQPChannel, error) {
+			return nil, fmt.Errorf("the error")
+		}, func(ch declare.AMQPChannel) error {
+			t.Fatal("should not be called")
+			return nil
+		})
+		require.EqualError(t, err, "open channel: the error")
+	})
+
+	main.Run("ReturnBrokerError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		brokerErr := &amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no exchange 'theExchange'"}
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().ExchangeDelete("theExchange", true, false).Return(brokerErr),
+			ch.EXPECT().Close().Return(amqp.ErrClosed),
+		)
+
+		err := declare.OnChannel(func() (declare.AMQPChannel, error) {
+			return ch, nil
+		}, func(ch declare.AMQPChannel) error {
+			return ch.ExchangeDelete("theExchange", true, false)
+		})
+		require.Equal(t, brokerErr, err)
+	})
+
+	main.Run("CloseChannelErrored", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().QueueUnbind("theQueue", "theKey", "theExchange", nil).Return(nil),
+			ch.EXPECT().Close().Return(fmt.Errorf("the error")),
+		)
+
+		err := declare.OnChannel(func() (declare.AMQPChannel, error) {
+			return ch, nil
+		}, func(ch declare.AMQPChannel) error {
+			return ch.QueueUnbind("theQueue", "theKey", "theExchange", nil)
+		})
+		require.EqualError(t, err, "close channel: the error")
+	})
+}
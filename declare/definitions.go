@@ -0,0 +1,345 @@
+package declare
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/streadway/amqp"
+	"gopkg.in/yaml.v3"
+)
+
+// DefinitionError points to the place in a definitions file the topology could not be parsed at.
+type DefinitionError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *DefinitionError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// LoadFile reads the topology from a YAML or JSON definitions file, see Parse.
+func LoadFile(path string) (Topology, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Topology{}, fmt.Errorf("definitions: %v", err)
+	}
+
+	return Parse(path, data)
+}
+
+// Parse parses the topology from YAML or JSON definitions, file is used in errors only.
+//
+// The format is a subset of the RabbitMQ definitions export:
+// exchanges, queues and bindings with arguments are read, other top level keys like users or policies are skipped.
+// The vhost of an object is ignored, the topology is declared in the vhost the Dialer is connected to.
+// A queue may set exclusive, which the export does not have.
+//
+// The result could be passed to the Dialer with WithTopology.
+func Parse(file string, data []byte) (Topology, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Topology{}, fmt.Errorf("%s: %v", file, err)
+	}
+
+	p := &parser{file: file}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return Topology{}, p.errorf(&doc, "definitions must be a mapping")
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return Topology{}, p.errorf(root, "definitions must be a mapping")
+	}
+
+	var t Topology
+	for i := 0; i < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+
+		var err error
+		switch key.Value {
+		case "exchanges":
+			err = p.each(val, "exchanges", func(n *yaml.Node) error {
+				e, err := p.exchange(n)
+				t.Exchanges = append(t.Exchanges, e)
+				return err
+			})
+		case "queues":
+			err = p.each(val, "queues", func(n *yaml.Node) error {
+				q, err := p.queue(n)
+				t.Queues = append(t.Queues, q)
+				return err
+			})
+		case "bindings":
+			err = p.each(val, "bindings", func(n *yaml.Node) error {
+				return p.binding(n, &t)
+			})
+		}
+		if err != nil {
+			return Topology{}, err
+		}
+	}
+
+	return t, nil
+}
+
+type parser struct {
+	file string
+}
+
+func (p *parser) errorf(n *yaml.Node, format string, args ...interface{}) error {
+	return &DefinitionError{
+		File:   p.file,
+		Line:   n.Line,
+		Column: n.Column,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) each(n *yaml.Node, name string, fn func(n *yaml.Node) error) error {
+	if n.Kind != yaml.SequenceNode {
+		return p.errorf(n, "%s must be a list", name)
+	}
+
+	for _, item := range n.Content {
+		if item.Kind != yaml.MappingNode {
+			return p.errorf(item, "%s item must be a mapping", name)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) fields(n *yaml.Node, fn func(key, val *yaml.Node) (bool, error)) error {
+	for i := 0; i < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+
+		known, err := fn(key, val)
+		if err != nil {
+			return err
+		}
+		if !known {
+			return p.errorf(key, "unknown field %s", key.Value)
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) exchange(n *yaml.Node) (ExchangeDecl, error) {
+	e := ExchangeDecl{}
+	err := p.fields(n, func(key, val *yaml.Node) (bool, error) {
+		switch key.Value {
+		case "name":
+			return true, p.string(key, val, &e.Name)
+		case "type":
+			return true, p.string(key, val, &e.Kind)
+		case "durable":
+			return true, p.bool(key, val, &e.Durable)
+		case "auto_delete":
+			return true, p.bool(key, val, &e.AutoDelete)
+		case "internal":
+			return true, p.bool(key, val, &e.Internal)
+		case "arguments":
+			return true, p.table(key, val, &e.Args)
+		case "vhost":
+			return true, nil
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return ExchangeDecl{}, err
+	}
+
+	if e.Name == "" {
+		return ExchangeDecl{}, p.errorf(n, "exchange: name is required")
+	}
+
+	switch {
+	case e.Kind == "":
+		return ExchangeDecl{}, p.errorf(n, "exchange %s: type is required", e.Name)
+	case e.Kind == amqp.ExchangeDirect,
+		e.Kind == amqp.ExchangeFanout,
+		e.Kind == amqp.ExchangeTopic,
+		e.Kind == amqp.ExchangeHeaders,
+		strings.HasPrefix(e.Kind, "x-"):
+	default:
+		return ExchangeDecl{}, p.errorf(n, "exchange %s: unknown type %s", e.Name, e.Kind)
+	}
+
+	return e, nil
+}
+
+func (p *parser) queue(n *yaml.Node) (QueueDecl, error) {
+	q := QueueDecl{}
+	var queueType string
+	var typeNode *yaml.Node
+	err := p.fields(n, func(key, val *yaml.Node) (bool, error) {
+		switch key.Value {
+		case "name":
+			return true, p.string(key, val, &q.Name)
+		case "type":
+			typeNode = val
+			return true, p.string(key, val, &queueType)
+		case "durable":
+			return true, p.bool(key, val, &q.Durable)
+		case "auto_delete":
+			return true, p.bool(key, val, &q.AutoDelete)
+		case "exclusive":
+			return true, p.bool(key, val, &q.Exclusive)
+		case "arguments":
+			return true, p.table(key, val, &q.Args)
+		case "vhost":
+			return true, nil
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return QueueDecl{}, err
+	}
+
+	if q.Name == "" {
+		return QueueDecl{}, p.errorf(n, "queue: name is required")
+	}
+
+	// newer exports have the queue type next to the x-queue-type argument.
+	if queueType != "" && queueType != "classic" {
+		if argType, ok := q.Args["x-queue-type"]; ok && argType != queueType {
+			return QueueDecl{}, p.errorf(typeNode, "queue %s: type %s does not match x-queue-type argument %v", q.Name, queueType, argType)
+		}
+		if q.Args == nil {
+			q.Args = amqp.Table{}
+		}
+		q.Args["x-queue-type"] = queueType
+	}
+
+	return q, nil
+}
+
+func (p *parser) binding(n *yaml.Node, t *Topology) error {
+	var source, destination, destinationType, key string
+	var args amqp.Table
+	var destinationTypeNode *yaml.Node
+	err := p.fields(n, func(k, val *yaml.Node) (bool, error) {
+		switch k.Value {
+		case "source":
+			return true, p.string(k, val, &source)
+		case "destination":
+			return true, p.string(k, val, &destination)
+		case "destination_type":
+			destinationTypeNode = val
+			return true, p.string(k, val, &destinationType)
+		case "routing_key":
+			return true, p.string(k, val, &key)
+		case "arguments":
+			return true, p.table(k, val, &args)
+		case "vhost":
+			return true, nil
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if source == "" {
+		return p.errorf(n, "binding: source is required")
+	}
+	if destination == "" {
+		return p.errorf(n, "binding: destination is required")
+	}
+
+	switch destinationType {
+	case "queue":
+		t.Bindings = append(t.Bindings, Binding{
+			Queue:    destination,
+			Exchange: source,
+			Key:      key,
+			Args:     args,
+		})
+	case "exchange":
+		t.ExchangeBindings = append(t.ExchangeBindings, ExchangeBinding{
+			Destination: destination,
+			Source:      source,
+			Key:         key,
+			Args:        args,
+		})
+	case "":
+		return p.errorf(n, "binding: destination_type is required")
+	default:
+		return p.errorf(destinationTypeNode, "binding: destination_type must be queue or exchange, got %s", destinationType)
+	}
+
+	return nil
+}
+
+func (p *parser) string(key, val *yaml.Node, v *string) error {
+	if val.Kind != yaml.ScalarNode || val.Tag == "!!null" {
+		return p.errorf(val, "%s must be a string", key.Value)
+	}
+
+	*v = val.Value
+	return nil
+}
+
+func (p *parser) bool(key, val *yaml.Node, v *bool) error {
+	if val.Kind != yaml.ScalarNode || val.Tag != "!!bool" {
+		return p.errorf(val, "%s must be a boolean", key.Value)
+	}
+
+	return val.Decode(v)
+}
+
+func (p *parser) table(key, val *yaml.Node, v *amqp.Table) error {
+	if val.Kind == yaml.ScalarNode && val.Tag == "!!null" {
+		return nil
+	}
+	if val.Kind != yaml.MappingNode {
+		return p.errorf(val, "%s must be a mapping", key.Value)
+	}
+
+	var m map[string]interface{}
+	if err := val.Decode(&m); err != nil {
+		return p.errorf(val, "%s: %v", key.Value, err)
+	}
+
+	t := toTable(m)
+	if err := t.Validate(); err != nil {
+		return p.errorf(val, "%s: %v", key.Value, err)
+	}
+
+	*v = t
+	return nil
+}
+
+func toTable(m map[string]interface{}) amqp.Table {
+	t := make(amqp.Table, len(m))
+	for k, v := range m {
+		t[k] = toField(v)
+	}
+
+	return t
+}
+
+func toField(v interface{}) interface{} {
+	switch fv := v.(type) {
+	case map[string]interface{}:
+		return toTable(fv)
+	case []interface{}:
+		for i := range fv {
+			fv[i] = toField(fv[i])
+		}
+		return fv
+	}
+
+	return v
+}
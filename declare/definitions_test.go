@@ -0,0 +1,148 @@
+package declare_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/makasim/amqpextra/declare"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFile(main *testing.T) {
+	main.Run("DefinitionsExport", func(t *testing.T) {
+		topology, err := declare.LoadFile("testdata/definitions.json")
+		require.NoError(t, err)
+
+		assert.Equal(t, declare.Topology{
+			Exchanges: []declare.ExchangeDecl{
+				{Name: "orders", Kind: "topic", Durable: true, Args: amqp.Table{}},
+				{Name: "orders.audit", Kind: "fanout", Durable: true, Internal: true, Args: amqp.Table{"alternate-exchange": "unrouted"}},
+			},
+			Queues: []declare.QueueDecl{
+				{Name: "orders.created", Durable: true, Args: amqp.Table{"x-message-ttl": 60000, "x-queue-type": "quorum"}},
+			},
+			Bindings: []declare.Binding{
+				{Queue: "orders.created", Exchange: "orders", Key: "order.created", Args: amqp.Table{}},
+			},
+			ExchangeBindings: []declare.ExchangeBinding{
+				{Destination: "orders.audit", Source: "orders", Key: "#", Args: amqp.Table{}},
+			},
+		}, topology)
+	})
+
+	main.Run("FileNotExist", func(t *testing.T) {
+		_, err := declare.LoadFile("testdata/notExist.json")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "definitions: open testdata/notExist.json")
+	})
+}
+
+func TestParse(main *testing.T) {
+	main.Run("YAML", func(t *testing.T) {
+		topology, err := declare.Parse("topology.yaml", []byte(`
+exchanges:
+  - name: events
+    type: headers
+queues:
+  - name: events.stream
+    type: stream
+    durable: true
+    arguments:
+      x-max-length-bytes: 1000
+      x-nested:
+        foo: [1, bar]
+  - name: worker
+    exclusive: true
+    auto_delete: true
+bindings:
+  - source: events
+    destination: worker
+    destination_type: queue
+    arguments:
+      x-match: any
+`))
+		require.NoError(t, err)
+
+		assert.Equal(t, declare.Topology{
+			Exchanges: []declare.ExchangeDecl{
+				{Name: "events", Kind: "headers"},
+			},
+			Queues: []declare.QueueDecl{
+				{Name: "events.stream", Durable: true, Args: amqp.Table{
+					"x-max-length-bytes": 1000,
+					"x-nested":           amqp.Table{"foo": []interface{}{1, "bar"}},
+					"x-queue-type":       "stream",
+				}},
+				{Name: "worker", Exclusive: true, AutoDelete: true},
+			},
+			Bindings: []declare.Binding{
+				{Queue: "worker", Exchange: "events", Args: amqp.Table{"x-match": "any"}},
+			},
+		}, topology)
+	})
+
+	main.Run("Errors", func(t *testing.T) {
+		cases := map[string]struct {
+			data string
+			err  string
+		}{
+			"NotMapping": {
+				data: "- foo",
+				err:  "topology.yaml:1:1: definitions must be a mapping",
+			},
+			"ExchangesNotList": {
+				data: "exchanges: foo",
+				err:  "topology.yaml:1:12: exchanges must be a list",
+			},
+			"ExchangeNoName": {
+				data: "exchanges:\n  - type: direct",
+				err:  "topology.yaml:2:5: exchange: name is required",
+			},
+			"ExchangeUnknownType": {
+				data: "exchanges:\n  - name: foo\n    type: bar",
+				err:  "topology.yaml:2:5: exchange foo: unknown type bar",
+			},
+			"ExchangeUnknownField": {
+				data: "exchanges:\n  - name: foo\n    type: direct\n    durabel: true",
+				err:  "topology.yaml:4:5: unknown field durabel",
+			},
+			"QueueDurableNotBool": {
+				data: "queues:\n  - name: foo\n    durable: yes please",
+				err:  "topology.yaml:3:14: durable must be a boolean",
+			},
+			"QueueTypeMismatch": {
+				data: "queues:\n  - name: foo\n    type: quorum\n    arguments:\n      x-queue-type: stream",
+				err:  "topology.yaml:3:11: queue foo: type quorum does not match x-queue-type argument stream",
+			},
+			"QueueArgumentsNotMapping": {
+				data: "queues:\n  - name: foo\n    arguments: [foo]",
+				err:  "topology.yaml:3:16: arguments must be a mapping",
+			},
+			"BindingNoSource": {
+				data: "bindings:\n  - destination: foo\n    destination_type: queue",
+				err:  "topology.yaml:2:5: binding: source is required",
+			},
+			"BindingUnknownDestinationType": {
+				data: "bindings:\n  - source: foo\n    destination: bar\n    destination_type: stream",
+				err:  "topology.yaml:4:23: binding: destination_type must be queue or exchange, got stream",
+			},
+			"JSON": {
+				data: "{\n  \"queues\": [\n    {\"name\": \"foo\", \"durable\": \"true\"}\n  ]\n}",
+				err:  "topology.yaml:3:32: durable must be a boolean",
+			},
+		}
+
+		for name, tc := range cases {
+			tc := tc
+			t.Run(name, func(t *testing.T) {
+				_, err := declare.Parse("topology.yaml", []byte(tc.data))
+				require.EqualError(t, err, tc.err)
+
+				var defErr *declare.DefinitionError
+				require.True(t, errors.As(err, &defErr))
+			})
+		}
+	})
+}
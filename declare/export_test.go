@@ -0,0 +1,5 @@
+package declare
+
+var VerifyChannels = verify
+
+var OnChannel = onChannel
@@ -0,0 +1,49 @@
+package declare
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/makasim/amqpextra"
+	"github.com/streadway/amqp"
+)
+
+// RetryQueueName returns the name of the queue messages of queue wait delay in before they are delivered again.
+func RetryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", queue, delay.Milliseconds())
+}
+
+// ParkingLotQueueName returns the name of the queue messages of queue are moved to once they are out of retry attempts.
+func ParkingLotQueueName(queue string) string {
+	return queue + ".parking-lot"
+}
+
+// RetryQueues declares a durable retry queue for each delay and a parking lot queue.
+// A message published to a retry queue expires after the delay and is dead-lettered back to queue.
+func RetryQueues(
+	ctx context.Context,
+	c *amqpextra.Dialer,
+	queue string,
+	delays ...time.Duration,
+) error {
+	for _, delay := range delays {
+		if delay.Milliseconds() < 1 {
+			return fmt.Errorf("retry delay must be at least 1ms")
+		}
+
+		if _, err := Queue(ctx, c, RetryQueueName(queue, delay), true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}); err != nil {
+			return fmt.Errorf("retry queue: %v", err)
+		}
+	}
+
+	if _, err := Queue(ctx, c, ParkingLotQueueName(queue), true, false, false, false, amqp.Table{}); err != nil {
+		return fmt.Errorf("parking lot queue: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package declare
+
+import (
+	"fmt"
+
+	"github.com/makasim/amqpextra"
+	"github.com/streadway/amqp"
+)
+
+// AMQPChannel is an interface for streadway's *amqp.Channel methods the declare helpers and the topology use.
+type AMQPChannel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error
+	ExchangeDelete(name string, ifUnused, noWait bool) error
+	QueueUnbind(name, key, exchange string, args amqp.Table) error
+	QueuePurge(name string, noWait bool) (int, error)
+	QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error)
+	Close() error
+}
+
+// ExchangeDecl describes an exchange to declare.
+type ExchangeDecl struct {
+	Name       string
+	Kind       string
+	Durable    bool
+	AutoDelete bool
+	Internal   bool
+	NoWait     bool
+	Args       amqp.Table
+}
+
+// QueueDecl describes a queue to declare.
+type QueueDecl struct {
+	Name       string
+	Durable    bool
+	AutoDelete bool
+	Exclusive  bool
+	NoWait     bool
+	Args       amqp.Table
+}
+
+// Binding binds Queue to Exchange with Key.
+type Binding struct {
+	Queue    string
+	Exchange string
+	Key      string
+	NoWait   bool
+	Args     amqp.Table
+}
+
+// ExchangeBinding binds Destination exchange to Source exchange with Key.
+type ExchangeBinding struct {
+	Destination string
+	Source      string
+	Key         string
+	NoWait      bool
+	Args        amqp.Table
+}
+
+// Topology describes exchanges, queues and bindings between them.
+// It is declared in dependency order: exchanges, queues, queue bindings and then exchange bindings.
+//
+// Pass it to the Dialer with WithTopology, so it is declared again on every new connection,
+// and non-durable exchanges, queues and bindings are back after the broker restart or failover.
+type Topology struct {
+	Exchanges        []ExchangeDecl
+	Queues           []QueueDecl
+	Bindings         []Binding
+	ExchangeBindings []ExchangeBinding
+}
+
+// WithTopology configure the Dialer to declare the topology on every established connection
+// before the connection is handed out to consumers and publishers.
+func WithTopology(t Topology) amqpextra.Option {
+	return amqpextra.WithOnConnect(t.DeclareConn)
+}
+
+// DeclareConn opens a channel on the connection, declares the topology and closes the channel.
+func (t Topology) DeclareConn(conn amqpextra.AMQPConnection) error {
+	amqpConn, ok := conn.(interface {
+		Channel() (*amqp.Channel, error)
+	})
+	if !ok {
+		return fmt.Errorf("topology: connection %T cannot open channels", conn)
+	}
+
+	return onChannel(func() (AMQPChannel, error) {
+		return amqpConn.Channel()
+	}, t.Declare)
+}
+
+// Declare declares the topology on the channel.
+// It stops on the first error, the channel is closed by the broker then.
+func (t Topology) Declare(ch AMQPChannel) error {
+	for _, e := range t.Exchanges {
+		if err := ch.ExchangeDeclare(e.Name, e.Kind, e.Durable, e.AutoDelete, e.Internal, e.NoWait, e.Args); err != nil {
+			return fmt.Errorf("topology: exchange %s: %v", e.Name, err)
+		}
+	}
+
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, q.Exclusive, q.NoWait, q.Args); err != nil {
+			return fmt.Errorf("topology: queue %s: %v", q.Name, err)
+		}
+	}
+
+	for _, b := range t.Bindings {
+		if err := ch.QueueBind(b.Queue, b.Key, b.Exchange, b.NoWait, b.Args); err != nil {
+			return fmt.Errorf("topology: bind queue %s to exchange %s with key %s: %v", b.Queue, b.Exchange, b.Key, err)
+		}
+	}
+
+	for _, b := range t.ExchangeBindings {
+		if err := ch.ExchangeBind(b.Destination, b.Key, b.Source, b.NoWait, b.Args); err != nil {
+			return fmt.Errorf("topology: bind exchange %s to exchange %s with key %s: %v", b.Destination, b.Source, b.Key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package declare_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/makasim/amqpextra/declare"
+	"github.com/makasim/amqpextra/declare/mock_declare"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologyDeclare(main *testing.T) {
+	main.Run("DeclareInDependencyOrder", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().ExchangeDeclare("fooExchange", "topic", true, false, false, false, nil).Return(nil),
+			ch.EXPECT().ExchangeDeclare("barExchange", "fanout", false, true, false, false, nil).Return(nil),
+			ch.EXPECT().QueueDeclare("theQueue", true, false, false, false, amqp.Table{"x-queue-type": "quorum"}).
+				Return(amqp.Queue{Name: "theQueue"}, nil),
+			ch.EXPECT().QueueBind("theQueue", "foo.*", "fooExchange", false, nil).Return(nil),
+			ch.EXPECT().QueueBind("theQueue", "", "barExchange", false, amqp.Table{"bar": "barVal"}).Return(nil),
+			ch.EXPECT().ExchangeBind("barExchange", "foo.bar", "fooExchange", false, nil).Return(nil),
+		)
+
+		err := declare.Topology{
+			ExchangeBindings: []declare.ExchangeBinding{
+				{Destination: "barExchange", Source: "fooExchange", Key: "foo.bar"},
+			},
+			Bindings: []declare.Binding{
+				{Queue: "theQueue", Exchange: "fooExchange", Key: "foo.*"},
+				{Queue: "theQueue", Exchange: "barExchange", Args: amqp.Table{"bar": "barVal"}},
+			},
+			Queues: []declare.QueueDecl{
+				{Name: "theQueue", Durable: true, Args: amqp.Table{"x-queue-type": "quorum"}},
+			},
+			Exchanges: []declare.ExchangeDecl{
+				{Name: "fooExchange", Kind: "topic", Durable: true},
+				{Name: "barExchange", Kind: "fanout", AutoDelete: true},
+			},
+		}.Declare(ch)
+		require.NoError(t, err)
+	})
+
+	main.Run("StopOnFirstError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().ExchangeDeclare("theExchange", "direct", false, false, false, false, nil).Return(nil)
+		ch.EXPECT().QueueDeclare("theQueue", false, false, false, false, nil).Return(amqp.Queue{}, nil)
+		ch.EXPECT().QueueBind("theQueue", "theKey", "theExchange", false, nil).Return(fmt.Errorf("the error"))
+
+		err := declare.Topology{
+			Exchanges: []declare.ExchangeDecl{{Name: "theExchange", Kind: "direct"}},
+			Queues:    []declare.QueueDecl{{Name: "theQueue"}},
+			Bindings: []declare.Binding{
+				{Queue: "theQueue", Exchange: "theExchange", Key: "theKey"},
+				{Queue: "theQueue", Exchange: "theExchange", Key: "otherKey"},
+			},
+			ExchangeBindings: []declare.ExchangeBinding{
+				{Destination: "theExchange", Source: "amq.topic"},
+			},
+		}.Declare(ch)
+		require.EqualError(t, err, "topology: bind queue theQueue to exchange theExchange with key theKey: the error")
+	})
+}
@@ -0,0 +1,158 @@
+package declare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/makasim/amqpextra"
+	"github.com/streadway/amqp"
+)
+
+// ObjectKind tells whether a drift is about an exchange or a queue.
+type ObjectKind string
+
+const (
+	ExchangeObject ObjectKind = "exchange"
+	QueueObject    ObjectKind = "queue"
+)
+
+// Problem tells how an object on the broker differs from the topology.
+type Problem string
+
+const (
+	// Missing object does not exist on the broker.
+	Missing Problem = "missing"
+	// Unknown object exists, but its properties and arguments could not be compared with the topology.
+	// The broker compares them only on an active declare, which would create the object if it was deleted meanwhile.
+	Unknown Problem = "unknown"
+)
+
+// Drift is an object of the topology that does not match the broker, or might not.
+type Drift struct {
+	Kind    ObjectKind
+	Name    string
+	Problem Problem
+	// Reason is the broker's explanation.
+	Reason string
+}
+
+// Report lists the drifts found by Verify.
+type Report struct {
+	// Drifts lists the objects missing on the broker.
+	Drifts []Drift
+	// Unknown lists the objects that exist on the broker but could not be compared with the topology.
+	Unknown []Drift
+}
+
+// OK tells whether every object of the topology exists on the broker.
+func (r Report) OK() bool {
+	return len(r.Drifts) == 0
+}
+
+func (r Report) String() string {
+	if r.OK() && len(r.Unknown) == 0 {
+		return "topology exists on the broker"
+	}
+
+	lines := make([]string, 0, len(r.Drifts)+len(r.Unknown))
+	for _, drifts := range [][]Drift{r.Drifts, r.Unknown} {
+		for _, d := range drifts {
+			lines = append(lines, fmt.Sprintf("%s %s is %s: %s", d.Kind, d.Name, d.Problem, d.Reason))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Verify compares the exchanges and queues of the topology with the broker using passive declares only,
+// so nothing is created or changed on the broker.
+// Each object is checked on a throwaway channel since a failed check closes the channel.
+// A passive declare tells only whether the object exists, so an existing object is reported as Unknown:
+// its properties and arguments are not compared, that takes an active declare.
+// The pre-defined amq.* exchanges are only checked to exist.
+// Bindings could not be checked without changing the broker.
+func Verify(ctx context.Context, c *amqpextra.Dialer, t Topology) (Report, error) {
+	conn, err := c.Connection(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return verify(ctx, func() (AMQPChannel, error) {
+		return conn.Channel()
+	}, t)
+}
+
+func verify(ctx context.Context, openCh func() (AMQPChannel, error), t Topology) (Report, error) {
+	r := Report{}
+	check := func(kind ObjectKind, name string, hasProperties bool, passive func(ch AMQPChannel) error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		amqpErr, err := verifyOnChannel(openCh, passive)
+		if err != nil {
+			return fmt.Errorf("verify %s %s: %v", kind, name, err)
+		}
+		if amqpErr != nil {
+			switch amqpErr.Code {
+			case amqp.NotFound:
+				r.Drifts = append(r.Drifts, Drift{Kind: kind, Name: name, Problem: Missing, Reason: amqpErr.Reason})
+				return nil
+			case amqp.ResourceLocked:
+				r.Unknown = append(r.Unknown, Drift{Kind: kind, Name: name, Problem: Unknown, Reason: amqpErr.Reason})
+				return nil
+			}
+
+			return fmt.Errorf("verify %s %s: %v", kind, name, amqpErr)
+		}
+
+		if hasProperties {
+			r.Unknown = append(r.Unknown, Drift{Kind: kind, Name: name, Problem: Unknown, Reason: "a passive declare does not compare properties and arguments"})
+		}
+
+		return nil
+	}
+
+	for _, e := range t.Exchanges {
+		e := e
+		if e.Name == "" {
+			continue
+		}
+
+		if err := check(ExchangeObject, e.Name, !strings.HasPrefix(e.Name, "amq."), func(ch AMQPChannel) error {
+			return ch.ExchangeDeclarePassive(e.Name, e.Kind, e.Durable, e.AutoDelete, e.Internal, false, e.Args)
+		}); err != nil {
+			return Report{}, err
+		}
+	}
+
+	for _, q := range t.Queues {
+		q := q
+		if q.Name == "" {
+			continue
+		}
+
+		if err := check(QueueObject, q.Name, true, func(ch AMQPChannel) error {
+			_, err := ch.QueueDeclarePassive(q.Name, q.Durable, q.AutoDelete, q.Exclusive, false, q.Args)
+			return err
+		}); err != nil {
+			return Report{}, err
+		}
+	}
+
+	return r, nil
+}
+
+// verifyOnChannel runs fn on a new channel and returns the broker's error apart from other errors.
+func verifyOnChannel(openCh func() (AMQPChannel, error), fn func(ch AMQPChannel) error) (*amqp.Error, error) {
+	err := onChannel(openCh, fn)
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr, nil
+	}
+
+	return nil, err
+}
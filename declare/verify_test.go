@@ -0,0 +1,155 @@
+package declare_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/makasim/amqpextra/declare"
+	"github.com/makasim/amqpextra/declare/mock_declare"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(main *testing.T) {
+	main.Run("Exists", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().ExchangeDeclarePassive("theExchange", "topic", true, false, false, false, nil).Return(nil),
+			ch.EXPECT().Close().Return(nil),
+			ch.EXPECT().ExchangeDeclarePassive("amq.topic", "topic", true, false, false, false, nil).Return(nil),
+			ch.EXPECT().Close().Return(nil),
+			ch.EXPECT().QueueDeclarePassive("theQueue", true, false, false, false, amqp.Table{"x-queue-type": "quorum"}).
+				Return(amqp.Queue{Name: "theQueue"}, nil),
+			ch.EXPECT().Close().Return(nil),
+		)
+
+		opened := 0
+		report, err := declare.VerifyChannels(context.Background(), func() (declare.AMQPChannel, error) {
+			opened++
+			return ch, nil
+		}, declare.Topology{
+			Exchanges: []declare.ExchangeDecl{
+				{Name: "theExchange", Kind: "topic", Durable: true, NoWait: true},
+				{Name: "amq.topic", Kind: "topic", Durable: true},
+			},
+			Queues: []declare.QueueDecl{
+				{Name: "theQueue", Durable: true, Args: amqp.Table{"x-queue-type": "quorum"}},
+			},
+			Bindings: []declare.Binding{
+				{Queue: "theQueue", Exchange: "theExchange"},
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, report.OK())
+		require.Empty(t, report.Drifts)
+		assert.Equal(t, []declare.Drift{
+			{
+				Kind:    declare.ExchangeObject,
+				Name:    "theExchange",
+				Problem: declare.Unknown,
+				Reason:  "a passive declare does not compare properties and arguments",
+			},
+			{
+				Kind:    declare.QueueObject,
+				Name:    "theQueue",
+				Problem: declare.Unknown,
+				Reason:  "a passive declare does not compare properties and arguments",
+			},
+		}, report.Unknown)
+		require.Equal(t, 3, opened)
+	})
+
+	main.Run("Empty", func(t *testing.T) {
+		report, err := declare.VerifyChannels(context.Background(), func() (declare.AMQPChannel, error) {
+			return nil, fmt.Errorf("should not be called")
+		}, declare.Topology{})
+		require.NoError(t, err)
+		require.True(t, report.OK())
+		require.Equal(t, "topology exists on the broker", report.String())
+	})
+
+	main.Run("MissingAndUnknown", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().ExchangeDeclarePassive("theExchange", "direct", false, false, false, false, nil).
+				Return(&amqp.Error{Code: amqp.NotFound, Reason: "NOT_FOUND - no exchange 'theExchange'"}),
+			ch.EXPECT().Close().Return(amqp.ErrClosed),
+			ch.EXPECT().QueueDeclarePassive("fooQueue", true, false, false, false, amqp.Table{"x-message-ttl": 1000}).
+				Return(amqp.Queue{Name: "fooQueue"}, nil),
+			ch.EXPECT().Close().Return(nil),
+			ch.EXPECT().QueueDeclarePassive("lockedQueue", false, false, true, false, nil).
+				Return(amqp.Queue{}, &amqp.Error{Code: amqp.ResourceLocked, Reason: "RESOURCE_LOCKED"}),
+			ch.EXPECT().Close().Return(amqp.ErrClosed),
+		)
+
+		report, err := declare.VerifyChannels(context.Background(), func() (declare.AMQPChannel, error) {
+			return ch, nil
+		}, declare.Topology{
+			Exchanges: []declare.ExchangeDecl{{Name: "theExchange", Kind: "direct"}},
+			Queues: []declare.QueueDecl{
+				{Name: "fooQueue", Durable: true, Args: amqp.Table{"x-message-ttl": 1000}},
+				{Name: "lockedQueue", Exclusive: true},
+			},
+		})
+		require.NoError(t, err)
+		require.False(t, report.OK())
+		assert.Equal(t, []declare.Drift{
+			{
+				Kind:    declare.ExchangeObject,
+				Name:    "theExchange",
+				Problem: declare.Missing,
+				Reason:  "NOT_FOUND - no exchange 'theExchange'",
+			},
+		}, report.Drifts)
+		assert.Equal(t, `exchange theExchange is missing: NOT_FOUND - no exchange 'theExchange'
+queue fooQueue is unknown: a passive declare does not compare properties and arguments
+queue lockedQueue is unknown: RESOURCE_LOCKED`, report.String())
+	})
+
+	main.Run("OpenChannelErrored", func(t *testing.T) {
+		_, err := declare.VerifyChannels(context.Background(), func() (declare.AMQPChannel, error) {
+			return nil, fmt.Errorf("the error")
+		}, declare.Topology{
+			Queues: []declare.QueueDecl{{Name: "theQueue"}},
+		})
+		require.EqualError(t, err, "verify queue theQueue: open channel: the error")
+	})
+
+	main.Run("UnexpectedBrokerError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_declare.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().QueueDeclarePassive("theQueue", false, false, false, false, nil).
+			Return(amqp.Queue{}, &amqp.Error{Code: amqp.AccessRefused, Reason: "ACCESS_REFUSED"})
+		ch.EXPECT().Close().Return(amqp.ErrClosed)
+
+		_, err := declare.VerifyChannels(context.Background(), func() (declare.AMQPChannel, error) {
+			return ch, nil
+		}, declare.Topology{
+			Queues: []declare.QueueDecl{{Name: "theQueue"}},
+		})
+		require.EqualError(t, err, "verify queue theQueue: Exception (403) Reason: \"ACCESS_REFUSED\"")
+	})
+
+	main.Run("ContextDone", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := declare.VerifyChannels(ctx, func() (declare.AMQPChannel, error) {
+			return nil, fmt.Errorf("should not be called")
+		}, declare.Topology{
+			Queues: []declare.QueueDecl{{Name: "theQueue"}},
+		})
+		require.Equal(t, context.Canceled, err)
+	})
+}
@@ -9,9 +9,11 @@ import (
 
 	"fmt"
 
+	"github.com/makasim/amqpextra/backoff"
 	"github.com/makasim/amqpextra/consumer"
 	"github.com/makasim/amqpextra/logger"
 	"github.com/makasim/amqpextra/publisher"
+	"github.com/makasim/amqpextra/rpc"
 	"github.com/streadway/amqp"
 )
 
@@ -20,12 +22,24 @@ type State struct {
 	Unready *Unready
 }
 
-type Ready struct{}
+// Ready is sent once at least one connection is established.
+type Ready struct {
+	// Connections is the number of established connections.
+	Connections int
+	// PoolSize is the number of connections Dialer keeps up.
+	PoolSize int
+}
 
 type Unready struct {
 	Err error
 }
 
+type memberState struct {
+	member int
+	conn   *Connection
+	err    error
+}
+
 // Option could be used to configure Dialer
 type Option func(c *Dialer)
 
@@ -58,9 +72,13 @@ type config struct {
 	amqpDial   func(url string, c amqp.Config) (AMQPConnection, error)
 	amqpConfig amqp.Config
 
-	logger      logger.Logger
-	retryPeriod time.Duration
-	ctx         context.Context
+	logger       logger.Logger
+	retryPeriod  time.Duration
+	backoff      backoff.Backoff
+	stablePeriod time.Duration
+	poolSize     int
+	ctx          context.Context
+	onConnect    []func(conn AMQPConnection) error
 }
 
 // Dialer is responsible for keeping the connection up.
@@ -78,6 +96,7 @@ type Dialer struct {
 	stateChs []chan State
 
 	internalStateChan chan State
+	memberStateCh     chan memberState
 
 	closedCh chan struct{}
 }
@@ -110,9 +129,11 @@ func NewDialer(opts ...Option) (*Dialer, error) {
 			},
 
 			retryPeriod: time.Second * 5,
+			poolSize:    1,
 			logger:      logger.Discard,
 		},
 		internalStateChan: make(chan State),
+		memberStateCh:     make(chan memberState),
 		connCh:            make(chan *Connection),
 		closedCh:          make(chan struct{}),
 	}
@@ -151,7 +172,15 @@ func NewDialer(opts ...Option) (*Dialer, error) {
 		return nil, fmt.Errorf("retryPeriod must be greater then zero")
 	}
 
-	go c.connectState()
+	if c.backoff == nil {
+		c.backoff = backoff.Constant(c.retryPeriod)
+	}
+
+	if c.poolSize < 1 {
+		return nil, fmt.Errorf("pool size must be greater than zero")
+	}
+
+	go c.poolState()
 
 	return c, nil
 }
@@ -191,6 +220,28 @@ func WithContext(ctx context.Context) Option {
 func WithRetryPeriod(dur time.Duration) Option {
 	return func(c *Dialer) {
 		c.retryPeriod = dur
+		c.backoff = nil
+	}
+}
+
+// WithBackoff configure how much time to wait before next dial attempt.
+// The backoff is reset once a connection stayed ready for at least stablePeriod.
+// It takes precedence over WithRetryPeriod if set after it.
+// A stateful backoff should implement backoff.Cloner, otherwise it is shared by the connections of a pool.
+func WithBackoff(b backoff.Backoff, stablePeriod time.Duration) Option {
+	return func(c *Dialer) {
+		c.backoff = b
+		c.stablePeriod = stablePeriod
+	}
+}
+
+// WithPoolSize configure how many connections Dialer keeps up. Default: 1.
+// Each connection is dialed and reconnected on its own.
+// Connections are handed out by Dialer.ConnectionCh() and Dialer.Connection() in turn.
+// Every connection gets its own clone of the backoff configured by WithBackoff, see backoff.Cloner.
+func WithPoolSize(size int) Option {
+	return func(c *Dialer) {
+		c.poolSize = size
 	}
 }
 
@@ -201,6 +252,16 @@ func WithConnectionProperties(props amqp.Table) Option {
 	}
 }
 
+// WithOnConnect configure functions called on every established connection before it is handed out,
+// so consumers and publishers are not ready until they all succeed.
+// They could be used to declare the topology, see declare.WithTopology.
+// If a function fails, the connection is closed and dialed again after a wait period.
+func WithOnConnect(hooks ...func(conn AMQPConnection) error) Option {
+	return func(c *Dialer) {
+		c.onConnect = append(c.onConnect, hooks...)
+	}
+}
+
 // WithNotify helps subscribe on Dialer ready/unready events.
 func WithNotify(stateCh chan State) Option {
 	return func(c *Dialer) {
@@ -286,22 +347,110 @@ func (c *Dialer) Publisher(opts ...publisher.Option) (*publisher.Publisher, erro
 	return NewPublisher(c.ConnectionCh(), opts...)
 }
 
-// connectState is a starting point.
-// It chooses URL and dials the server.
-// Once connection is established it pass control to Dialer.connectedState()
-// If connection is closed or lost Dialer.connectedState() returns control back to Dialer.connectState()
-// Exits on Dialer.Close()
-func (c *Dialer) connectState() {
+func (c *Dialer) RPCClient(opts ...rpc.ClientOption) (*rpc.Client, error) {
+	opts = append([]rpc.ClientOption{
+		rpc.WithClientLogger(c.logger),
+		rpc.WithClientContext(c.ctx),
+	}, opts...)
+
+	return NewRPCClient(c.ConnectionCh(), opts...)
+}
+
+// RPCServer returns a server that consumes requests and publishes replies over the dialer connections.
+func (c *Dialer) RPCServer(handler rpc.Handler, opts ...rpc.ServerOption) (*rpc.Server, error) {
+	opts = append([]rpc.ServerOption{
+		rpc.WithServerLogger(c.logger),
+		rpc.WithServerContext(c.ctx),
+	}, opts...)
+
+	return NewRPCServer(c.ConnectionCh(), handler, opts...)
+}
+
+// poolState is a starting point.
+// It starts pool members and keeps track of their connections.
+// It serves Dialer.ConnectionCh(), Dialer.Connection() and Dialer.Notify() methods.
+// The connections of the pool members are handed out in turn.
+// Exits once all the members are stopped.
+func (c *Dialer) poolState() {
 	defer close(c.connCh)
 	defer close(c.closedCh)
 	defer c.cancelFunc()
 	defer c.logger.Printf("[DEBUG] connection closed")
 
-	i := 0
-	l := len(c.amqpUrls)
-
 	c.logger.Printf("[DEBUG] connection unready")
+
+	wg := &sync.WaitGroup{}
+	startedWg := &sync.WaitGroup{}
+	for i := 0; i < c.poolSize; i++ {
+		wg.Add(1)
+		startedWg.Add(1)
+		go func(member int) {
+			defer wg.Done()
+			c.connectState(member, startedWg)
+		}(i)
+	}
+	startedWg.Wait()
+
+	membersDoneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(membersDoneCh)
+	}()
+
+	conns := make([]*Connection, c.poolSize)
+	connected := 0
+	next := 0
 	state := State{Unready: &Unready{Err: amqp.ErrClosed}}
+	for {
+		var connCh chan *Connection
+		var conn *Connection
+		if connected > 0 {
+			for conns[next] == nil {
+				next = (next + 1) % c.poolSize
+			}
+			connCh = c.connCh
+			conn = conns[next]
+		}
+
+		select {
+		case c.internalStateChan <- state:
+			continue
+		case connCh <- conn:
+			next = (next + 1) % c.poolSize
+			continue
+		case ms := <-c.memberStateCh:
+			changed := (conns[ms.member] == nil) != (ms.conn == nil)
+			conns[ms.member] = ms.conn
+			if changed && ms.conn != nil {
+				connected++
+			} else if changed {
+				connected--
+			}
+
+			if connected > 0 && changed {
+				state = c.notifyReady(connected)
+			} else if connected == 0 {
+				state = c.notifyUnready(ms.err)
+			}
+		case <-membersDoneCh:
+			return
+		}
+	}
+}
+
+// connectState chooses URL and dials the server.
+// Once connection is established it pass control to Dialer.connectedState()
+// If connection is closed or lost Dialer.connectedState() returns control back to Dialer.connectState()
+// Exits on Dialer.Close()
+func (c *Dialer) connectState(member int, startedWg *sync.WaitGroup) {
+	startedOnce := &sync.Once{}
+	defer startedOnce.Do(startedWg.Done)
+
+	retry := backoff.NewRetry(backoff.Clone(c.backoff), c.stablePeriod)
+
+	l := len(c.amqpUrls)
+	i := member % l
+
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -315,52 +464,57 @@ func (c *Dialer) connectState() {
 		connCh := make(chan AMQPConnection)
 		errorCh := make(chan error)
 
+		c.logger.Printf("[DEBUG] dialing")
 		go func() {
-			c.logger.Printf("[DEBUG] dialing")
 			if conn, err := c.amqpDial(url, c.amqpConfig); err != nil {
 				errorCh <- err
 			} else {
 				connCh <- conn
 			}
 		}()
+		startedOnce.Do(startedWg.Done)
 
-	loop2:
-		for {
+		select {
+		case conn := <-connCh:
 			select {
-			case c.internalStateChan <- state:
-				continue
-			case conn := <-connCh:
-				select {
-				case <-c.ctx.Done():
-					c.closeConn(conn)
-					return
-				default:
-				}
+			case <-c.ctx.Done():
+				c.closeConn(conn)
+				return
+			default:
+			}
 
-				if err := c.connectedState(conn); err != nil {
-					c.logger.Printf("[DEBUG] connection unready: %s", err)
-					state = c.notifyUnready(err)
-					break loop2
+			if err := c.callOnConnect(conn); err != nil {
+				c.logger.Printf("[ERROR] on connect: %s", err)
+				c.closeConn(conn)
+				if retryErr := c.waitRetry(member, retry, err); retryErr != nil {
+					continue
 				}
 
 				return
-			case err := <-errorCh:
-				c.logger.Printf("[DEBUG] connection unready: %v", err)
-				if retryErr := c.waitRetry(err); retryErr != nil {
-					state = State{Unready: &Unready{Err: retryErr}}
-					break loop2
-				}
+			}
 
-				return
+			retry.Ready()
+			if err := c.connectedState(member, conn); err != nil {
+				c.logger.Printf("[DEBUG] connection unready: %s", err)
+				c.memberUnready(member, err)
+				continue
 			}
+
+			return
+		case err := <-errorCh:
+			c.logger.Printf("[DEBUG] connection unready: %v", err)
+			if retryErr := c.waitRetry(member, retry, err); retryErr != nil {
+				continue
+			}
+
+			return
 		}
 	}
 }
 
-// connectedState serves Dialer.ConnectionCh() and Dialer.Connection() methods.
-// It shares an established connection with all the clients who requests it.
+// connectedState passes an established connection to Dialer.poolState() which shares it with all the clients who requests it.
 // Once connection is lost or closed it gives control back to Dialer.connectState().
-func (c *Dialer) connectedState(amqpConn AMQPConnection) error {
+func (c *Dialer) connectedState(member int, amqpConn AMQPConnection) error {
 	defer c.closeConn(amqpConn)
 
 	lostCh := make(chan struct{})
@@ -369,22 +523,34 @@ func (c *Dialer) connectedState(amqpConn AMQPConnection) error {
 
 	conn := &Connection{amqpConn: amqpConn, lostCh: lostCh}
 	c.logger.Printf("[DEBUG] connection ready")
-	state := c.notifyReady()
-	for {
-		select {
-		case c.internalStateChan <- state:
-			continue
-		case c.connCh <- conn:
-			continue
-		case err, ok := <-internalCloseCh:
-			if !ok {
-				err = amqp.ErrClosed
-			}
+	c.memberReady(member, conn)
+	select {
+	case err, ok := <-internalCloseCh:
+		if !ok {
+			err = amqp.ErrClosed
+		}
+		return err
+	case <-c.ctx.Done():
+		return nil
+	}
+}
+
+func (c *Dialer) callOnConnect(conn AMQPConnection) error {
+	for _, hook := range c.onConnect {
+		if err := hook(conn); err != nil {
 			return err
-		case <-c.ctx.Done():
-			return nil
 		}
 	}
+
+	return nil
+}
+
+func (c *Dialer) memberReady(member int, conn *Connection) {
+	c.memberStateCh <- memberState{member: member, conn: conn}
+}
+
+func (c *Dialer) memberUnready(member int, err error) {
+	c.memberStateCh <- memberState{member: member, err: err}
 }
 
 func (c *Dialer) notifyUnready(err error) State {
@@ -401,8 +567,8 @@ func (c *Dialer) notifyUnready(err error) State {
 	return state
 }
 
-func (c *Dialer) notifyReady() State {
-	state := State{Ready: &Ready{}}
+func (c *Dialer) notifyReady(connected int) State {
+	state := State{Ready: &Ready{Connections: connected, PoolSize: c.poolSize}}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	for _, stateCh := range c.stateChs {
@@ -415,25 +581,15 @@ func (c *Dialer) notifyReady() State {
 	return state
 }
 
-func (c *Dialer) waitRetry(err error) error {
-	timer := time.NewTimer(c.retryPeriod)
-	defer func() {
-		timer.Stop()
-		select {
-		case <-timer.C:
-		default:
-		}
-	}()
-	state := c.notifyUnready(err)
-	for {
-		select {
-		case c.internalStateChan <- state:
-			continue
-		case <-timer.C:
-			return err
-		case <-c.ctx.Done():
-			return nil
-		}
+func (c *Dialer) waitRetry(member int, retry *backoff.Retry, err error) error {
+	timer := retry.Timer()
+	defer backoff.Stop(timer)
+	c.memberUnready(member, err)
+	select {
+	case <-timer.C:
+		return err
+	case <-c.ctx.Done():
+		return nil
 	}
 }
 
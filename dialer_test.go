@@ -12,8 +12,12 @@ import (
 
 	"context"
 
+	"sync"
+	"sync/atomic"
+
 	"github.com/golang/mock/gomock"
 	"github.com/makasim/amqpextra"
+	"github.com/makasim/amqpextra/backoff"
 	"github.com/makasim/amqpextra/logger"
 	"github.com/makasim/amqpextra/mock_amqpextra"
 	"github.com/streadway/amqp"
@@ -87,6 +91,16 @@ func TestOptions(main *testing.T) {
 		require.EqualError(t, err, "retryPeriod must be greater then zero")
 	})
 
+	main.Run("ZeroPoolSize", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, err := amqpextra.NewDialer(
+			amqpextra.WithURL("URL"),
+			amqpextra.WithPoolSize(0),
+		)
+		require.EqualError(t, err, "pool size must be greater than zero")
+	})
+
 	main.Run("ErrorEmptyURl", func(t *testing.T) {
 		defer goleak.VerifyNone(t)
 
@@ -388,6 +402,43 @@ func TestConnectState(main *testing.T) {
 `, l.Logs())
 	})
 
+	main.Run("ReadyAfterBackoff", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+
+		closeCh := make(chan *amqp.Error)
+		stateCh := make(chan amqpextra.State, 2)
+		amqpConn := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn.EXPECT().Close().Return(nil)
+		amqpConn.EXPECT().NotifyClose(any()).Return(closeCh)
+
+		b := &backoffStub{durs: []time.Duration{time.Millisecond * 10, time.Millisecond * 20}}
+
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(amqpDialStub(fmt.Errorf("the error"), fmt.Errorf("the error"), amqpConn)),
+			amqpextra.WithBackoff(b, time.Hour),
+			amqpextra.WithLogger(l),
+		)
+
+		require.NoError(t, err)
+		defer dialer.Close()
+
+		conn := <-dialer.ConnectionCh()
+		assertConnNotLost(t, conn)
+
+		dialer.Close()
+		assertClosed(t, dialer)
+
+		assert.Equal(t, 2, b.nextCalls)
+		assert.Equal(t, 0, b.resetCalls)
+	})
+
 	main.Run("GetConnectionTimeout", func(t *testing.T) {
 		defer goleak.VerifyNone(t)
 
@@ -844,10 +895,291 @@ func TestConnectedState(main *testing.T) {
 [DEBUG] connection ready
 [ERROR] connection closed errored
 [DEBUG] connection closed
+`, l.Logs())
+	})
+
+	main.Run("CallOnConnectBeforeReady", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+
+		closeCh := make(chan *amqp.Error)
+		stateCh := make(chan amqpextra.State, 2)
+		amqpConn := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn.EXPECT().Close().Return(nil)
+		amqpConn.EXPECT().NotifyClose(any()).Return(closeCh)
+
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(amqpDialStub(amqpConn)),
+			amqpextra.WithLogger(l),
+			amqpextra.WithOnConnect(func(conn amqpextra.AMQPConnection) error {
+				require.Equal(t, amqpConn, conn)
+				l.Printf("[TEST] on connect")
+
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+
+		assertReady(t, stateCh)
+
+		conn := <-dialer.ConnectionCh()
+		assertConnNotLost(t, conn)
+
+		dialer.Close()
+		assertClosed(t, dialer)
+
+		assert.Equal(t, `[DEBUG] connection unready
+[DEBUG] dialing
+[TEST] on connect
+[DEBUG] connection ready
+[DEBUG] connection closed
+`, l.Logs())
+	})
+
+	main.Run("RetryIfOnConnectErrored", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		l := logger.NewTest()
+		stateCh := make(chan amqpextra.State, 2)
+
+		amqpConn0 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn0.EXPECT().Close().Return(nil)
+
+		closeCh1 := make(chan *amqp.Error)
+		amqpConn1 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn1.EXPECT().NotifyClose(any()).Return(closeCh1)
+		amqpConn1.EXPECT().Close().Return(nil)
+
+		calls := 0
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(amqpDialStub(amqpConn0, amqpConn1)),
+			amqpextra.WithRetryPeriod(time.Millisecond*50),
+			amqpextra.WithLogger(l),
+			amqpextra.WithOnConnect(func(conn amqpextra.AMQPConnection) error {
+				calls++
+				if calls == 1 {
+					return fmt.Errorf("the error")
+				}
+
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+
+		assertUnready(t, stateCh, "the error")
+		assertReady(t, stateCh)
+
+		conn := <-dialer.ConnectionCh()
+		assertConnNotLost(t, conn)
+
+		dialer.Close()
+		assertClosed(t, dialer)
+
+		assert.Equal(t, `[DEBUG] connection unready
+[DEBUG] dialing
+[ERROR] on connect: the error
+[DEBUG] dialing
+[DEBUG] connection ready
+[DEBUG] connection closed
 `, l.Logs())
 	})
 }
 
+func TestPool(main *testing.T) {
+	main.Run("ConnectionsHandedOutInTurn", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan amqpextra.State, 2)
+
+		amqpConn0 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn0.EXPECT().NotifyClose(any()).Return(make(chan *amqp.Error))
+		amqpConn0.EXPECT().Close().Return(nil)
+
+		amqpConn1 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn1.EXPECT().NotifyClose(any()).Return(make(chan *amqp.Error))
+		amqpConn1.EXPECT().Close().Return(nil)
+
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(concurrentAMQPDialStub(amqpConn0, amqpConn1)),
+			amqpextra.WithPoolSize(2),
+		)
+		require.NoError(t, err)
+
+		assertPoolReady(t, stateCh, 1, 2)
+		assertPoolReady(t, stateCh, 2, 2)
+
+		conns := map[*amqpextra.Connection]int{}
+		for i := 0; i < 4; i++ {
+			conns[<-dialer.ConnectionCh()]++
+		}
+		require.Len(t, conns, 2)
+		for _, n := range conns {
+			require.Equal(t, 2, n)
+		}
+
+		dialer.Close()
+		assertClosed(t, dialer)
+	})
+
+	main.Run("MembersHaveOwnBackoff", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan amqpextra.State, 2)
+
+		amqpConn0 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn0.EXPECT().NotifyClose(any()).Return(make(chan *amqp.Error))
+		amqpConn0.EXPECT().Close().Return(nil)
+
+		amqpConn1 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn1.EXPECT().NotifyClose(any()).Return(make(chan *amqp.Error))
+		amqpConn1.EXPECT().Close().Return(nil)
+
+		b := &cloningBackoffStub{}
+
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(concurrentAMQPDialStub(amqpConn0, amqpConn1)),
+			amqpextra.WithPoolSize(2),
+			amqpextra.WithBackoff(b, time.Minute),
+		)
+		require.NoError(t, err)
+
+		assertPoolReady(t, stateCh, 1, 2)
+		assertPoolReady(t, stateCh, 2, 2)
+
+		dialer.Close()
+		assertClosed(t, dialer)
+
+		require.Equal(t, int32(2), atomic.LoadInt32(&b.cloneCalls))
+	})
+
+	main.Run("MemberReconnectsOnItsOwn", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan amqpextra.State, 2)
+
+		closeCh0 := make(chan *amqp.Error, 1)
+		amqpConn0 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn0.EXPECT().NotifyClose(any()).Return(closeCh0)
+		amqpConn0.EXPECT().Close().Return(nil)
+
+		amqpConn1 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn1.EXPECT().NotifyClose(any()).Return(make(chan *amqp.Error))
+		amqpConn1.EXPECT().Close().Return(nil)
+
+		amqpConn2 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn2.EXPECT().NotifyClose(any()).Return(make(chan *amqp.Error))
+		amqpConn2.EXPECT().Close().Return(nil)
+
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(concurrentAMQPDialStub(
+				amqpConn0,
+				amqpConn1,
+				time.Millisecond*50,
+				amqpConn2,
+			)),
+			amqpextra.WithPoolSize(2),
+		)
+		require.NoError(t, err)
+
+		assertPoolReady(t, stateCh, 1, 2)
+		assertPoolReady(t, stateCh, 2, 2)
+
+		closeCh0 <- amqp.ErrClosed
+
+		assertPoolReady(t, stateCh, 1, 2)
+		assertPoolReady(t, stateCh, 2, 2)
+
+		dialer.Close()
+		assertClosed(t, dialer)
+	})
+
+	main.Run("UnreadyOnceAllMembersLost", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan amqpextra.State, 2)
+
+		closeCh0 := make(chan *amqp.Error, 1)
+		amqpConn0 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn0.EXPECT().NotifyClose(any()).Return(closeCh0)
+		amqpConn0.EXPECT().Close().Return(nil)
+
+		closeCh1 := make(chan *amqp.Error, 1)
+		amqpConn1 := mock_amqpextra.NewMockAMQPConnection(ctrl)
+		amqpConn1.EXPECT().NotifyClose(any()).Return(closeCh1)
+		amqpConn1.EXPECT().Close().Return(nil)
+
+		dialer, err := amqpextra.NewDialer(
+			amqpextra.WithURL("amqp://rabbitmq.host"),
+			amqpextra.WithNotify(stateCh),
+			amqpextra.WithAMQPDial(concurrentAMQPDialStub(
+				amqpConn0,
+				amqpConn1,
+				fmt.Errorf("the error"),
+				fmt.Errorf("the error"),
+			)),
+			amqpextra.WithPoolSize(2),
+			amqpextra.WithRetryPeriod(time.Hour),
+		)
+		require.NoError(t, err)
+
+		assertPoolReady(t, stateCh, 1, 2)
+		assertPoolReady(t, stateCh, 2, 2)
+
+		closeCh0 <- amqp.ErrClosed
+		assertPoolReady(t, stateCh, 1, 2)
+
+		closeCh1 <- amqp.ErrClosed
+		assertUnready(t, stateCh, amqp.ErrClosed.Error())
+
+		dialer.Close()
+		assertClosed(t, dialer)
+	})
+}
+
+func assertPoolReady(t *testing.T, stateCh <-chan amqpextra.State, connections, poolSize int) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	select {
+	case state := <-stateCh:
+		require.Nil(t, state.Unready, fmt.Sprintf("%+v", state))
+		require.NotNil(t, state.Ready, fmt.Sprintf("%+v", state))
+		require.Equal(t, connections, state.Ready.Connections)
+		require.Equal(t, poolSize, state.Ready.PoolSize)
+	case <-timer.C:
+		t.Fatal("dialer must be ready")
+	}
+}
+
 func assertUnready(t *testing.T, stateCh <-chan amqpextra.State, errString string) {
 	timer := time.NewTimer(time.Millisecond * 100)
 	defer timer.Stop()
@@ -933,6 +1265,48 @@ func assertConnLost(t *testing.T, conn *amqpextra.Connection) {
 	}
 }
 
+type backoffStub struct {
+	durs       []time.Duration
+	nextCalls  int
+	resetCalls int
+}
+
+func (b *backoffStub) Next() time.Duration {
+	d := b.durs[b.nextCalls]
+	b.nextCalls++
+	return d
+}
+
+func (b *backoffStub) Reset() {
+	b.resetCalls++
+}
+
+type cloningBackoffStub struct {
+	cloneCalls int32
+}
+
+func (b *cloningBackoffStub) Next() time.Duration {
+	return time.Millisecond
+}
+
+func (b *cloningBackoffStub) Reset() {}
+
+func (b *cloningBackoffStub) Clone() backoff.Backoff {
+	atomic.AddInt32(&b.cloneCalls, 1)
+	return &cloningBackoffStub{}
+}
+
+func concurrentAMQPDialStub(conns ...interface{}) func(url string, config amqp.Config) (amqpextra.AMQPConnection, error) {
+	mu := &sync.Mutex{}
+	dial := amqpDialStub(conns...)
+	return func(url string, config amqp.Config) (amqpextra.AMQPConnection, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return dial(url, config)
+	}
+}
+
 func any() gomock.Matcher {
 	return gomock.Any()
 }
@@ -64,14 +64,14 @@ waitOpened:
 	}
 
 	resultCh := make(chan error, 1)
-	h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		resultCh <- msg.Ack(false)
 
 		if string(msg.Body) == "Last!" {
 			close(resultCh)
 		}
 
-		return nil
+		return consumer.Settled
 	})
 
 	consumerStateCh := make(chan consumer.State, 1)
@@ -131,9 +131,9 @@ func TestConsumerWithExchange(t *testing.T) {
 	assertDialerReady(t, dialerStateCh)
 
 	gotMsg := make(chan struct{})
-	h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) interface{} {
+	h := consumer.HandlerFunc(func(ctx context.Context, msg amqp.Delivery) consumer.Result {
 		close(gotMsg)
-		return nil
+		return consumer.Settled
 	})
 
 	err = ch.ExchangeDeclare(
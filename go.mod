@@ -3,8 +3,10 @@ module github.com/makasim/amqpextra
 go 1.13
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/golang/mock v1.4.4
 	github.com/streadway/amqp v1.0.1-0.20200716223359-e6b33f460591
 	github.com/stretchr/testify v1.4.0
 	go.uber.org/goleak v1.0.0
+	gopkg.in/yaml.v3 v3.0.0-20200506231410-2ff61e1afc86
 )
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps records in memory. It is meant to be used in tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	nextID   int64
+	records  []Record
+	sent     map[int64]bool
+	dead     map[int64]bool
+	attempts map[int64]int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sent:     make(map[int64]bool),
+		dead:     make(map[int64]bool),
+		attempts: make(map[int64]int),
+	}
+}
+
+// Add adds a record to the store and returns its ID.
+func (s *MemoryStore) Add(rec Record) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	rec.ID = s.nextID
+	s.records = append(s.records, rec)
+
+	return rec.ID
+}
+
+func (s *MemoryStore) Fetch(_ context.Context, limit int) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0, limit)
+	for _, rec := range s.records {
+		if len(records) == limit {
+			break
+		}
+		if s.sent[rec.ID] || s.dead[rec.ID] {
+			continue
+		}
+
+		rec.Attempts = s.attempts[rec.ID]
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func (s *MemoryStore) MarkSent(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.sent[id] = true
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) MarkFailed(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.attempts[id]++
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) MarkDead(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.attempts[id]++
+		s.dead[id] = true
+	}
+
+	return nil
+}
+
+// Unsent returns records that are neither sent nor dead.
+func (s *MemoryStore) Unsent() []Record {
+	return s.filter(func(id int64) bool {
+		return !s.sent[id] && !s.dead[id]
+	})
+}
+
+// Dead returns records that are marked as dead.
+func (s *MemoryStore) Dead() []Record {
+	return s.filter(func(id int64) bool {
+		return s.dead[id]
+	})
+}
+
+func (s *MemoryStore) filter(fn func(id int64) bool) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0)
+	for _, rec := range s.records {
+		if fn(rec.ID) {
+			rec.Attempts = s.attempts[rec.ID]
+			records = append(records, rec)
+		}
+	}
+
+	return records
+}
@@ -0,0 +1,272 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/makasim/amqpextra/logger"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/streadway/amqp"
+)
+
+// Record is a message waiting in the outbox to be published.
+type Record struct {
+	ID         int64
+	Exchange   string
+	Key        string
+	Mandatory  bool
+	Publishing amqp.Publishing
+	// Attempts is how many times publishing the record has failed.
+	Attempts int
+}
+
+// Store keeps outbox records.
+// Records are added by the application in the same transaction as the domain changes,
+// the relay only fetches unsent records and marks them as sent, failed or dead.
+type Store interface {
+	// Fetch returns at most limit records which are neither sent nor dead, ordered by ID.
+	Fetch(ctx context.Context, limit int) ([]Record, error)
+	// MarkSent marks records as sent, they are not returned by Fetch anymore.
+	MarkSent(ctx context.Context, ids []int64) error
+	// MarkFailed increments the attempts of records which failed to be published, they are fetched again.
+	MarkFailed(ctx context.Context, ids []int64) error
+	// MarkDead increments the attempts of records which failed to be published for the last time,
+	// they are not returned by Fetch anymore.
+	MarkDead(ctx context.Context, ids []int64) error
+}
+
+type Option func(r *Relay)
+
+// Relay moves records from the outbox store to the broker.
+// A record is marked as sent only after the publisher returned no error for it,
+// so the publisher must be created with publisher.WithConfirmation option.
+// Records that failed are published again on the next poll, until they failed WithMaxAttempts times and are marked dead.
+//
+// Fetch does not lock records, so only one relay may run per store, otherwise records are published twice.
+type Relay struct {
+	publisher *publisher.Publisher
+	store     Store
+
+	ctx          context.Context
+	cancelFunc   context.CancelFunc
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	notifyCh     <-chan struct{}
+	logger       logger.Logger
+
+	closeCh chan struct{}
+}
+
+func New(p *publisher.Publisher, store Store, opts ...Option) (*Relay, error) {
+	r := &Relay{
+		publisher: p,
+		store:     store,
+
+		closeCh: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.publisher == nil {
+		return nil, fmt.Errorf("publisher must be not nil")
+	}
+
+	if !r.publisher.Confirmation() {
+		return nil, fmt.Errorf("publisher must be created with confirmation")
+	}
+
+	if r.store == nil {
+		return nil, fmt.Errorf("store must be not nil")
+	}
+
+	if r.ctx != nil {
+		r.ctx, r.cancelFunc = context.WithCancel(r.ctx)
+	} else {
+		r.ctx, r.cancelFunc = context.WithCancel(context.Background())
+	}
+
+	if r.pollInterval == 0 {
+		r.pollInterval = time.Second
+	}
+
+	if r.pollInterval < 0 {
+		return nil, fmt.Errorf("poll interval must be greater than 0")
+	}
+
+	if r.batchSize == 0 {
+		r.batchSize = 100
+	}
+
+	if r.batchSize < 0 {
+		return nil, fmt.Errorf("batch size must be greater than 0")
+	}
+
+	if r.maxAttempts == 0 {
+		r.maxAttempts = 10
+	}
+
+	if r.maxAttempts < 0 {
+		return nil, fmt.Errorf("max attempts must be greater than 0")
+	}
+
+	if r.logger == nil {
+		r.logger = logger.Discard
+	}
+
+	go r.relay()
+
+	return r, nil
+}
+
+func WithContext(ctx context.Context) Option {
+	return func(r *Relay) {
+		r.ctx = ctx
+	}
+}
+
+func WithLogger(l logger.Logger) Option {
+	return func(r *Relay) {
+		r.logger = l
+	}
+}
+
+// WithPollInterval tells how often the relay looks for unsent records. Default: 1s.
+func WithPollInterval(dur time.Duration) Option {
+	return func(r *Relay) {
+		r.pollInterval = dur
+	}
+}
+
+// WithBatchSize tells how many records are fetched and published at once. Default: 100.
+func WithBatchSize(size int) Option {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// WithMaxAttempts tells how many times a record is published before it is marked dead. Default: 10.
+// A dead record is logged and left in the store, so it does not hold up the records after it.
+func WithMaxAttempts(n int) Option {
+	return func(r *Relay) {
+		r.maxAttempts = n
+	}
+}
+
+// WithNotify makes the relay look for unsent records as soon as something is sent to notifyCh,
+// for example after the application committed a transaction with new records.
+// The relay still polls with the poll interval.
+func WithNotify(notifyCh <-chan struct{}) Option {
+	return func(r *Relay) {
+		r.notifyCh = notifyCh
+	}
+}
+
+func (r *Relay) Close() {
+	r.cancelFunc()
+}
+
+func (r *Relay) NotifyClosed() <-chan struct{} {
+	return r.closeCh
+}
+
+func (r *Relay) relay() {
+	defer close(r.closeCh)
+	defer r.cancelFunc()
+	defer r.logger.Printf("[DEBUG] relay stopped")
+
+	r.logger.Printf("[DEBUG] relay started")
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.relayBatch()
+			if err != nil {
+				r.logger.Printf("[ERROR] relay: %s", err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.notifyCh:
+		case <-r.publisher.NotifyClosed():
+			return
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// relayBatch publishes a batch of unsent records and returns how many records were fetched.
+func (r *Relay) relayBatch() (int, error) {
+	records, err := r.store.Fetch(r.ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("fetch: %v", err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	msgs := make([]publisher.Message, len(records))
+	for i, rec := range records {
+		msgs[i] = publisher.Message{
+			Exchange:   rec.Exchange,
+			Key:        rec.Key,
+			Mandatory:  rec.Mandatory,
+			Publishing: rec.Publishing,
+		}
+	}
+
+	results := r.publisher.PublishBatch(r.ctx, msgs)
+
+	sent := make([]int64, 0, len(records))
+	var failed, dead []int64
+	var publishErr error
+	for i, result := range results {
+		if result == nil {
+			sent = append(sent, records[i].ID)
+			continue
+		}
+
+		publishErr = result
+		if records[i].Attempts+1 >= r.maxAttempts {
+			r.logger.Printf("[ERROR] relay: record %d is dead after %d attempts: %s", records[i].ID, records[i].Attempts+1, result)
+			dead = append(dead, records[i].ID)
+			continue
+		}
+
+		failed = append(failed, records[i].ID)
+	}
+
+	// the relay context could be already done, the records must be marked anyway.
+	if len(sent) > 0 {
+		if err := r.store.MarkSent(context.Background(), sent); err != nil {
+			return 0, fmt.Errorf("mark sent: %v", err)
+		}
+	}
+	if len(failed) > 0 {
+		if err := r.store.MarkFailed(context.Background(), failed); err != nil {
+			return 0, fmt.Errorf("mark failed: %v", err)
+		}
+	}
+	if len(dead) > 0 {
+		if err := r.store.MarkDead(context.Background(), dead); err != nil {
+			return 0, fmt.Errorf("mark dead: %v", err)
+		}
+	}
+
+	if publishErr != nil {
+		return 0, fmt.Errorf("publish: %d of %d records failed, last error: %v", len(records)-len(sent), len(records), publishErr)
+	}
+
+	return len(records), nil
+}
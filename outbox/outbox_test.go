@@ -0,0 +1,368 @@
+package outbox_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/makasim/amqpextra/logger"
+	"github.com/makasim/amqpextra/outbox"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/makasim/amqpextra/publisher/mock_publisher"
+)
+
+func TestNew(main *testing.T) {
+	main.Run("ErrorIfPublisherNil", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, err := outbox.New(nil, outbox.NewMemoryStore())
+		require.EqualError(t, err, "publisher must be not nil")
+	})
+
+	main.Run("ErrorIfPublisherWithoutConfirmation", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection))
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = outbox.New(p, outbox.NewMemoryStore())
+		require.EqualError(t, err, "publisher must be created with confirmation")
+
+		p.Close()
+		<-p.NotifyClosed()
+	})
+
+	main.Run("ErrorIfStoreNil", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection), publisher.WithConfirmation(1))
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = outbox.New(p, nil)
+		require.EqualError(t, err, "store must be not nil")
+
+		p.Close()
+		<-p.NotifyClosed()
+	})
+
+	main.Run("ErrorIfBatchSizeLessThanZero", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection), publisher.WithConfirmation(1))
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = outbox.New(p, outbox.NewMemoryStore(), outbox.WithBatchSize(-1))
+		require.EqualError(t, err, "batch size must be greater than 0")
+
+		p.Close()
+		<-p.NotifyClosed()
+	})
+
+	main.Run("ErrorIfMaxAttemptsLessThanZero", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection), publisher.WithConfirmation(1))
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = outbox.New(p, outbox.NewMemoryStore(), outbox.WithMaxAttempts(-1))
+		require.EqualError(t, err, "max attempts must be greater than 0")
+
+		p.Close()
+		<-p.NotifyClosed()
+	})
+}
+
+func TestRelay(main *testing.T) {
+	main.Run("MarkSentOnlyAfterAck", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 2)
+		publishedCh := make(chan string, 3)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(gomock.Any()).AnyTimes()
+		ch.EXPECT().NotifyClose(gomock.Any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(gomock.Any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil)
+		ch.EXPECT().NotifyPublish(gomock.Any()).Return(confirmationCh)
+		ch.EXPECT().
+			Publish("theExchange", gomock.Any(), false, false, gomock.Any()).
+			DoAndReturn(func(_, key string, _, _ bool, _ amqp.Publishing) error {
+				publishedCh <- key
+
+				return nil
+			}).
+			Times(3)
+		ch.EXPECT().Close().AnyTimes()
+
+		connCh := make(chan *publisher.Connection, 1)
+		p, err := publisher.New(
+			connCh,
+			publisher.WithConfirmation(2),
+			publisher.WithInitFunc(func(_ publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+				return ch, nil
+			}),
+		)
+		require.NoError(t, err)
+		defer p.Close()
+
+		connCh <- publisher.NewConnection(mock_publisher.NewMockAMQPConnection(ctrl), nil)
+
+		store := outbox.NewMemoryStore()
+		store.Add(outbox.Record{Exchange: "theExchange", Key: "firstKey"})
+		store.Add(outbox.Record{Exchange: "theExchange", Key: "secondKey"})
+
+		l := logger.NewTest()
+		r, err := outbox.New(
+			p,
+			store,
+			outbox.WithPollInterval(time.Millisecond*50),
+			outbox.WithLogger(l),
+		)
+		require.NoError(t, err)
+		defer r.Close()
+
+		require.Equal(t, "firstKey", <-publishedCh)
+		require.Equal(t, "secondKey", <-publishedCh)
+		require.Len(t, store.Unsent(), 2)
+
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+
+		require.Equal(t, "secondKey", <-publishedCh)
+		unsent := store.Unsent()
+		require.Len(t, unsent, 1)
+		require.Equal(t, "secondKey", unsent[0].Key)
+
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+		require.Eventually(t, func() bool {
+			return len(store.Unsent()) == 0
+		}, time.Millisecond*200, time.Millisecond*10)
+
+		r.Close()
+		<-r.NotifyClosed()
+		p.Close()
+		<-p.NotifyClosed()
+
+		require.Equal(t, `[DEBUG] relay started
+[ERROR] relay: publish: 1 of 2 records failed, last error: confirmation: nack
+[DEBUG] relay stopped
+`, l.Logs())
+	})
+
+	main.Run("RelayOnNotify", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 1)
+		publishedCh := make(chan string, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(gomock.Any()).AnyTimes()
+		ch.EXPECT().NotifyClose(gomock.Any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(gomock.Any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil)
+		ch.EXPECT().NotifyPublish(gomock.Any()).Return(confirmationCh)
+		ch.EXPECT().
+			Publish(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_, key string, _, _ bool, _ amqp.Publishing) error {
+				publishedCh <- key
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().Close().AnyTimes()
+
+		connCh := make(chan *publisher.Connection, 1)
+		p, err := publisher.New(
+			connCh,
+			publisher.WithConfirmation(1),
+			publisher.WithInitFunc(func(_ publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+				return ch, nil
+			}),
+		)
+		require.NoError(t, err)
+		defer p.Close()
+
+		connCh <- publisher.NewConnection(mock_publisher.NewMockAMQPConnection(ctrl), nil)
+
+		store := outbox.NewMemoryStore()
+		notifyCh := make(chan struct{}, 1)
+
+		r, err := outbox.New(
+			p,
+			store,
+			outbox.WithPollInterval(time.Hour),
+			outbox.WithNotify(notifyCh),
+		)
+		require.NoError(t, err)
+		defer r.Close()
+
+		store.Add(outbox.Record{Key: "theKey"})
+		notifyCh <- struct{}{}
+
+		select {
+		case key := <-publishedCh:
+			require.Equal(t, "theKey", key)
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("record must be published")
+		}
+
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+
+		require.Eventually(t, func() bool {
+			return len(store.Unsent()) == 0
+		}, time.Millisecond*100, time.Millisecond*10)
+
+		r.Close()
+		<-r.NotifyClosed()
+		p.Close()
+		<-p.NotifyClosed()
+	})
+
+	main.Run("DeadAfterMaxAttempts", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 10)
+		var deliveryTag uint64
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(gomock.Any()).AnyTimes()
+		ch.EXPECT().NotifyClose(gomock.Any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(gomock.Any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil)
+		ch.EXPECT().NotifyPublish(gomock.Any()).Return(confirmationCh)
+		ch.EXPECT().
+			Publish(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_, key string, _, _ bool, _ amqp.Publishing) error {
+				deliveryTag++
+				confirmationCh <- amqp.Confirmation{DeliveryTag: deliveryTag, Ack: key != "unroutable"}
+
+				return nil
+			}).
+			Times(3)
+		ch.EXPECT().Close().AnyTimes()
+
+		connCh := make(chan *publisher.Connection, 1)
+		p, err := publisher.New(
+			connCh,
+			publisher.WithConfirmation(1),
+			publisher.WithInitFunc(func(_ publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+				return ch, nil
+			}),
+		)
+		require.NoError(t, err)
+		defer p.Close()
+
+		connCh <- publisher.NewConnection(mock_publisher.NewMockAMQPConnection(ctrl), nil)
+
+		store := outbox.NewMemoryStore()
+		store.Add(outbox.Record{Key: "unroutable"})
+		store.Add(outbox.Record{Key: "theKey"})
+
+		l := logger.NewTest()
+		r, err := outbox.New(
+			p,
+			store,
+			outbox.WithPollInterval(time.Millisecond*20),
+			outbox.WithBatchSize(1),
+			outbox.WithMaxAttempts(2),
+			outbox.WithLogger(l),
+		)
+		require.NoError(t, err)
+		defer r.Close()
+
+		require.Eventually(t, func() bool {
+			return len(store.Unsent()) == 0
+		}, time.Millisecond*500, time.Millisecond*10)
+
+		r.Close()
+		<-r.NotifyClosed()
+		p.Close()
+		<-p.NotifyClosed()
+
+		dead := store.Dead()
+		require.Len(t, dead, 1)
+		require.Equal(t, "unroutable", dead[0].Key)
+		require.Equal(t, 2, dead[0].Attempts)
+
+		require.Contains(t, l.Logs(), "[ERROR] relay: record 1 is dead after 2 attempts: confirmation: nack\n")
+	})
+
+	main.Run("StoppedWithPublisher", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		p, err := publisher.New(make(chan *publisher.Connection), publisher.WithConfirmation(1))
+		require.NoError(t, err)
+
+		r, err := outbox.New(p, outbox.NewMemoryStore())
+		require.NoError(t, err)
+
+		p.Close()
+
+		select {
+		case <-r.NotifyClosed():
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("relay must be closed")
+		}
+	})
+}
+
+func TestMemoryStore(main *testing.T) {
+	main.Run("FetchUnsentWithLimit", func(t *testing.T) {
+		store := outbox.NewMemoryStore()
+		require.Equal(t, int64(1), store.Add(outbox.Record{Key: "first"}))
+		require.Equal(t, int64(2), store.Add(outbox.Record{Key: "second"}))
+		require.Equal(t, int64(3), store.Add(outbox.Record{Key: "third"}))
+
+		require.NoError(t, store.MarkSent(context.Background(), []int64{1}))
+
+		records, err := store.Fetch(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, int64(2), records[0].ID)
+		require.Equal(t, "second", records[0].Key)
+
+		records, err = store.Fetch(context.Background(), 10)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+	})
+
+	main.Run("FailedFetchedAgainAndDeadNot", func(t *testing.T) {
+		store := outbox.NewMemoryStore()
+		store.Add(outbox.Record{Key: "first"})
+		store.Add(outbox.Record{Key: "second"})
+
+		require.NoError(t, store.MarkFailed(context.Background(), []int64{1}))
+		require.NoError(t, store.MarkFailed(context.Background(), []int64{1}))
+		require.NoError(t, store.MarkDead(context.Background(), []int64{2}))
+
+		records, err := store.Fetch(context.Background(), 10)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, "first", records[0].Key)
+		require.Equal(t, 2, records[0].Attempts)
+
+		dead := store.Dead()
+		require.Len(t, dead, 1)
+		require.Equal(t, "second", dead[0].Key)
+		require.Equal(t, 1, dead[0].Attempts)
+	})
+}
@@ -0,0 +1,231 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Execer is implemented by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type SQLStoreOption func(s *SQLStore)
+
+// SQLStore keeps records in a database/sql table with the following columns:
+//
+//	CREATE TABLE outbox (
+//	    id               BIGSERIAL PRIMARY KEY,
+//	    exchange         VARCHAR(255) NOT NULL,
+//	    routing_key      VARCHAR(255) NOT NULL,
+//	    mandatory        BOOLEAN NOT NULL,
+//	    headers          TEXT NOT NULL,
+//	    content_type     VARCHAR(255) NOT NULL,
+//	    content_encoding VARCHAR(255) NOT NULL,
+//	    delivery_mode    SMALLINT NOT NULL,
+//	    priority         SMALLINT NOT NULL,
+//	    correlation_id   VARCHAR(255) NOT NULL,
+//	    reply_to         VARCHAR(255) NOT NULL,
+//	    expiration       VARCHAR(255) NOT NULL,
+//	    message_id       VARCHAR(255) NOT NULL,
+//	    timestamp        BIGINT NOT NULL,
+//	    type             VARCHAR(255) NOT NULL,
+//	    user_id          VARCHAR(255) NOT NULL,
+//	    app_id           VARCHAR(255) NOT NULL,
+//	    body             BYTEA NOT NULL,
+//	    attempts         INTEGER NOT NULL DEFAULT 0,
+//	    sent_at          TIMESTAMP NULL,
+//	    dead_at          TIMESTAMP NULL
+//	);
+//
+// A dead record is kept with dead_at set, setting it back to NULL makes the relay publish the record again.
+// Fetch does not lock rows, only one relay may run per table.
+// Every amqp.Publishing field is stored.
+// Headers are stored as JSON, so their values come back as JSON types.
+// Timestamp is stored as Unix seconds, the precision AMQP carries, 0 stands for no timestamp.
+// sqlColumns are the record columns in the order Add writes and Fetch reads them.
+const sqlColumns = `exchange, routing_key, mandatory, headers, content_type, content_encoding, delivery_mode, priority, correlation_id, reply_to, expiration, message_id, timestamp, type, user_id, app_id, body`
+
+type SQLStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+}
+
+func NewSQLStore(db *sql.DB, table string, opts ...SQLStoreOption) *SQLStore {
+	s := &SQLStore{
+		db:    db,
+		table: table,
+		placeholder: func(_ int) string {
+			return "?"
+		},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithPlaceholder sets how query parameters are written, n starts from 1. Default: ?.
+func WithPlaceholder(f func(n int) string) SQLStoreOption {
+	return func(s *SQLStore) {
+		s.placeholder = f
+	}
+}
+
+// DollarPlaceholder writes query parameters as $1, $2 and so on, like PostgreSQL expects.
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// Add inserts a record using exec, pass the *sql.Tx the domain changes are written in.
+func (s *SQLStore) Add(ctx context.Context, exec Execer, rec Record) error {
+	headers, err := json.Marshal(rec.Publishing.Headers)
+	if err != nil {
+		return fmt.Errorf("headers: %v", err)
+	}
+
+	var timestamp int64
+	if !rec.Publishing.Timestamp.IsZero() {
+		timestamp = rec.Publishing.Timestamp.Unix()
+	}
+
+	_, err = exec.ExecContext(ctx, fmt.Sprintf(
+		`INSERT INTO %s (%s) VALUES (%s)`,
+		s.table,
+		sqlColumns,
+		s.placeholders(1, 17),
+	),
+		rec.Exchange,
+		rec.Key,
+		rec.Mandatory,
+		string(headers),
+		rec.Publishing.ContentType,
+		rec.Publishing.ContentEncoding,
+		rec.Publishing.DeliveryMode,
+		rec.Publishing.Priority,
+		rec.Publishing.CorrelationId,
+		rec.Publishing.ReplyTo,
+		rec.Publishing.Expiration,
+		rec.Publishing.MessageId,
+		timestamp,
+		rec.Publishing.Type,
+		rec.Publishing.UserId,
+		rec.Publishing.AppId,
+		rec.Publishing.Body,
+	)
+
+	return err
+}
+
+func (s *SQLStore) Fetch(ctx context.Context, limit int) ([]Record, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, %s, attempts FROM %s WHERE sent_at IS NULL AND dead_at IS NULL ORDER BY id LIMIT %d`,
+		sqlColumns,
+		s.table,
+		limit,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]Record, 0, limit)
+	for rows.Next() {
+		var rec Record
+		var headers string
+		var timestamp int64
+		if err := rows.Scan(
+			&rec.ID,
+			&rec.Exchange,
+			&rec.Key,
+			&rec.Mandatory,
+			&headers,
+			&rec.Publishing.ContentType,
+			&rec.Publishing.ContentEncoding,
+			&rec.Publishing.DeliveryMode,
+			&rec.Publishing.Priority,
+			&rec.Publishing.CorrelationId,
+			&rec.Publishing.ReplyTo,
+			&rec.Publishing.Expiration,
+			&rec.Publishing.MessageId,
+			&timestamp,
+			&rec.Publishing.Type,
+			&rec.Publishing.UserId,
+			&rec.Publishing.AppId,
+			&rec.Publishing.Body,
+			&rec.Attempts,
+		); err != nil {
+			return nil, err
+		}
+
+		if timestamp != 0 {
+			rec.Publishing.Timestamp = time.Unix(timestamp, 0)
+		}
+
+		if headers != "" && headers != "null" {
+			rec.Publishing.Headers = amqp.Table{}
+			if err := json.Unmarshal([]byte(headers), &rec.Publishing.Headers); err != nil {
+				return nil, fmt.Errorf("record %d: headers: %v", rec.ID, err)
+			}
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, rows.Err()
+}
+
+func (s *SQLStore) MarkSent(ctx context.Context, ids []int64) error {
+	return s.update(ctx, ids, "sent_at = %s", time.Now())
+}
+
+func (s *SQLStore) MarkFailed(ctx context.Context, ids []int64) error {
+	return s.update(ctx, ids, "attempts = attempts + 1")
+}
+
+func (s *SQLStore) MarkDead(ctx context.Context, ids []int64) error {
+	return s.update(ctx, ids, "attempts = attempts + 1, dead_at = %s", time.Now())
+}
+
+// update sets the columns of records by ids, set has a placeholder verb for each of args.
+func (s *SQLStore) update(ctx context.Context, ids []int64, set string, args ...interface{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	placeholders := make([]interface{}, len(args))
+	for i := range args {
+		placeholders[i] = s.placeholder(i + 1)
+	}
+
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		`UPDATE %s SET %s WHERE id IN (%s)`,
+		s.table,
+		fmt.Sprintf(set, placeholders...),
+		s.placeholders(len(placeholders)+1, len(ids)),
+	), args...)
+
+	return err
+}
+
+func (s *SQLStore) placeholders(from, n int) string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = s.placeholder(from + i)
+	}
+
+	return strings.Join(ps, ", ")
+}
@@ -0,0 +1,296 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/makasim/amqpextra/outbox"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+const sqlColumns = `exchange, routing_key, mandatory, headers, content_type, content_encoding, delivery_mode, priority, correlation_id, reply_to, expiration, message_id, timestamp, type, user_id, app_id, body`
+
+const insertQuery = `INSERT INTO outbox (` + sqlColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func TestSQLStore(main *testing.T) {
+	main.Run("Add", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectExec(insertQuery).
+			WithArgs(
+				"theExchange",
+				"theKey",
+				true,
+				`{"theHeader":"theValue"}`,
+				"text/plain",
+				"gzip",
+				2,
+				5,
+				"theCorrelationID",
+				"theReplyTo",
+				"1000",
+				"theMessageID",
+				1600000000,
+				"theType",
+				"theUser",
+				"theApp",
+				[]byte("theBody"),
+			).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		s := outbox.NewSQLStore(db, "outbox")
+		require.NoError(t, s.Add(context.Background(), db, fullRecord()))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("AddWithoutTimestampAndHeaders", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectExec(insertQuery).
+			WithArgs("", "theKey", false, "null", "", "", 0, 0, "", "", "", "", 0, "", "", "", []byte(nil)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		s := outbox.NewSQLStore(db, "outbox")
+		require.NoError(t, s.Add(context.Background(), db, outbox.Record{Key: "theKey"}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("AddWithDollarPlaceholder", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectExec(`INSERT INTO outbox (` + sqlColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		s := outbox.NewSQLStore(db, "outbox", outbox.WithPlaceholder(outbox.DollarPlaceholder))
+		require.NoError(t, s.Add(context.Background(), db, outbox.Record{}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("AddInTransaction", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(insertQuery).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		s := outbox.NewSQLStore(db, "outbox")
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, s.Add(context.Background(), tx, outbox.Record{}))
+		require.NoError(t, tx.Rollback())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("FetchUnsentInOrder", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectQuery(fetchQuery(10)).
+			WillReturnRows(newRecordRows().
+				AddRow(1, "theExchange", "theKey", true, `{"theHeader":"theValue"}`, "text/plain", "gzip", 2, 5, "theCorrelationID", "theReplyTo", "1000", "theMessageID", 1600000000, "theType", "theUser", "theApp", []byte("theBody"), 0).
+				AddRow(2, "", "otherKey", false, "null", "", "", 0, 0, "", "", "", "", 0, "", "", "", []byte{}, 3),
+			)
+
+		s := outbox.NewSQLStore(db, "outbox")
+		records, err := s.Fetch(context.Background(), 10)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		expected := fullRecord()
+		expected.ID = 1
+		require.Len(t, records, 2)
+		require.Equal(t, expected, records[0])
+		require.Equal(t, outbox.Record{ID: 2, Key: "otherKey", Publishing: amqp.Publishing{Body: []byte{}}, Attempts: 3}, records[1])
+	})
+
+	main.Run("FetchInvalidHeaders", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectQuery(fetchQuery(10)).
+			WillReturnRows(newRecordRows().
+				AddRow(3, "", "", false, "{", "", "", 0, 0, "", "", "", "", 0, "", "", "", []byte{}, 0),
+			)
+
+		s := outbox.NewSQLStore(db, "outbox")
+		_, err := s.Fetch(context.Background(), 10)
+		require.EqualError(t, err, "record 3: headers: unexpected end of JSON input")
+	})
+
+	main.Run("FetchErrored", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectQuery(fetchQuery(10)).
+			WillReturnError(fmt.Errorf("the error"))
+
+		s := outbox.NewSQLStore(db, "outbox")
+		_, err := s.Fetch(context.Background(), 10)
+		require.EqualError(t, err, "the error")
+	})
+
+	main.Run("HeadersRoundTrip", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		args := make([]*capture, 17)
+		matchers := make([]driver.Value, 17)
+		for i := range args {
+			args[i] = &capture{}
+			matchers[i] = args[i]
+		}
+
+		mock.ExpectExec(insertQuery).
+			WithArgs(matchers...).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		rec := fullRecord()
+		rec.Publishing.Headers = amqp.Table{
+			"string": "theValue",
+			"int":    1,
+			"bool":   true,
+			"table":  amqp.Table{"nested": "theValue"},
+		}
+
+		s := outbox.NewSQLStore(db, "outbox")
+		require.NoError(t, s.Add(context.Background(), db, rec))
+
+		row := []driver.Value{1}
+		for _, arg := range args {
+			row = append(row, arg.value)
+		}
+		row = append(row, 0)
+		mock.ExpectQuery(fetchQuery(1)).
+			WillReturnRows(newRecordRows().AddRow(row...))
+
+		records, err := s.Fetch(context.Background(), 1)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+		require.Len(t, records, 1)
+
+		// JSON brings numbers back as float64 and tables as maps.
+		require.Equal(t, amqp.Table{
+			"string": "theValue",
+			"int":    float64(1),
+			"bool":   true,
+			"table":  map[string]interface{}{"nested": "theValue"},
+		}, records[0].Publishing.Headers)
+
+		rec.ID = 1
+		rec.Publishing.Headers = records[0].Publishing.Headers
+		require.Equal(t, rec, records[0])
+	})
+
+	main.Run("MarkSent", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectExec(`UPDATE outbox SET sent_at = $1 WHERE id IN ($2, $3)`).
+			WithArgs(sqlmock.AnyArg(), 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		s := outbox.NewSQLStore(db, "outbox", outbox.WithPlaceholder(outbox.DollarPlaceholder))
+		require.NoError(t, s.MarkSent(context.Background(), []int64{1, 2}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("MarkFailed", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectExec(`UPDATE outbox SET attempts = attempts + 1 WHERE id IN ($1, $2)`).
+			WithArgs(1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		s := outbox.NewSQLStore(db, "outbox", outbox.WithPlaceholder(outbox.DollarPlaceholder))
+		require.NoError(t, s.MarkFailed(context.Background(), []int64{1, 2}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("MarkDead", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		mock.ExpectExec(`UPDATE outbox SET attempts = attempts + 1, dead_at = ? WHERE id IN (?)`).
+			WithArgs(sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		s := outbox.NewSQLStore(db, "outbox")
+		require.NoError(t, s.MarkDead(context.Background(), []int64{3}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	main.Run("MarkSentNothing", func(t *testing.T) {
+		db, mock := newSQLMock(t)
+		defer db.Close()
+
+		s := outbox.NewSQLStore(db, "outbox")
+		require.NoError(t, s.MarkSent(context.Background(), nil))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func newSQLMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	return db, mock
+}
+
+func newRecordRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "exchange", "routing_key", "mandatory", "headers", "content_type", "content_encoding", "delivery_mode", "priority",
+		"correlation_id", "reply_to", "expiration", "message_id", "timestamp", "type", "user_id", "app_id", "body", "attempts",
+	})
+}
+
+func fetchQuery(limit int) string {
+	return fmt.Sprintf(`SELECT id, %s, attempts FROM outbox WHERE sent_at IS NULL AND dead_at IS NULL ORDER BY id LIMIT %d`, sqlColumns, limit)
+}
+
+func fullRecord() outbox.Record {
+	return outbox.Record{
+		Exchange:  "theExchange",
+		Key:       "theKey",
+		Mandatory: true,
+		Publishing: amqp.Publishing{
+			Headers:         amqp.Table{"theHeader": "theValue"},
+			ContentType:     "text/plain",
+			ContentEncoding: "gzip",
+			DeliveryMode:    amqp.Persistent,
+			Priority:        5,
+			CorrelationId:   "theCorrelationID",
+			ReplyTo:         "theReplyTo",
+			Expiration:      "1000",
+			MessageId:       "theMessageID",
+			Timestamp:       time.Unix(1600000000, 0),
+			Type:            "theType",
+			UserId:          "theUser",
+			AppId:           "theApp",
+			Body:            []byte("theBody"),
+		},
+	}
+}
+
+// capture matches any argument and keeps its value.
+type capture struct {
+	value driver.Value
+}
+
+func (c *capture) Match(v driver.Value) bool {
+	c.value = v
+
+	return true
+}
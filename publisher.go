@@ -38,7 +38,7 @@ func proxyPublisherConn(
 				}
 
 				publisherConn := publisher.NewConnection(
-					conn.AMQPConnection(),
+					conn.amqpConn,
 					conn.NotifyLost(),
 				)
 
@@ -126,6 +126,20 @@ func (mr *MockAMQPChannelMockRecorder) NotifyPublish(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyPublish", reflect.TypeOf((*MockAMQPChannel)(nil).NotifyPublish), arg0)
 }
 
+// NotifyReturn mocks base method
+func (m *MockAMQPChannel) NotifyReturn(arg0 chan amqp.Return) chan amqp.Return {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyReturn", arg0)
+	ret0, _ := ret[0].(chan amqp.Return)
+	return ret0
+}
+
+// NotifyReturn indicates an expected call of NotifyReturn
+func (mr *MockAMQPChannelMockRecorder) NotifyReturn(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyReturn", reflect.TypeOf((*MockAMQPChannel)(nil).NotifyReturn), arg0)
+}
+
 // Publish mocks base method
 func (m *MockAMQPChannel) Publish(arg0, arg1 string, arg2, arg3 bool, arg4 amqp.Publishing) error {
 	m.ctrl.T.Helper()
@@ -139,3 +153,45 @@ func (mr *MockAMQPChannelMockRecorder) Publish(arg0, arg1, arg2, arg3, arg4 inte
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockAMQPChannel)(nil).Publish), arg0, arg1, arg2, arg3, arg4)
 }
+
+// Tx mocks base method
+func (m *MockAMQPChannel) Tx() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tx")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Tx indicates an expected call of Tx
+func (mr *MockAMQPChannelMockRecorder) Tx() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tx", reflect.TypeOf((*MockAMQPChannel)(nil).Tx))
+}
+
+// TxCommit mocks base method
+func (m *MockAMQPChannel) TxCommit() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TxCommit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TxCommit indicates an expected call of TxCommit
+func (mr *MockAMQPChannelMockRecorder) TxCommit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TxCommit", reflect.TypeOf((*MockAMQPChannel)(nil).TxCommit))
+}
+
+// TxRollback mocks base method
+func (m *MockAMQPChannel) TxRollback() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TxRollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TxRollback indicates an expected call of TxRollback
+func (mr *MockAMQPChannelMockRecorder) TxRollback() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TxRollback", reflect.TypeOf((*MockAMQPChannel)(nil).TxRollback))
+}
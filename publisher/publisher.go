@@ -2,11 +2,16 @@ package publisher
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/makasim/amqpextra/backoff"
 	"github.com/makasim/amqpextra/logger"
 	"github.com/streadway/amqp"
 )
@@ -33,11 +38,88 @@ type AMQPChannel interface {
 	NotifyFlow(c chan bool) chan bool
 	Close() error
 	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
 	Confirm(noWait bool) error
+	Tx() error
+	TxCommit() error
+	TxRollback() error
+}
+
+// ErrReturned is sent to Message.ResultCh when a mandatory message could not be routed and the server returned it.
+type ErrReturned struct {
+	ReplyCode  uint16
+	ReplyText  string
+	Exchange   string
+	RoutingKey string
+}
+
+func (e *ErrReturned) Error() string {
+	return fmt.Sprintf("message returned: %d %s", e.ReplyCode, e.ReplyText)
+}
+
+func newErrReturned(r amqp.Return) *ErrReturned {
+	return &ErrReturned{
+		ReplyCode:  r.ReplyCode,
+		ReplyText:  r.ReplyText,
+		Exchange:   r.Exchange,
+		RoutingKey: r.RoutingKey,
+	}
 }
 
 type Option func(p *Publisher)
 
+// slot is a publisher channel.
+// There is only one slot unless WithChannelPoolSize option is set.
+type slot struct {
+	id      int
+	stateCh chan State
+	retry   *backoff.Retry
+
+	// republish holds messages left unconfirmed by a closed channel, they are published again on the next one.
+	// A parked message is watched by its own goroutine which sends the context error as soon as the message context is done.
+	republishMu sync.Mutex
+	republish   []*parked
+
+	// deliveryTag is the tag of the last message published on the slot channel in confirm mode.
+	// It is reset every time the channel is reopened.
+	deliveryTag uint64
+}
+
+type slotState struct {
+	slot  *slot
+	ready bool
+	err   error
+}
+
+// inflight is a published message waiting for a confirmation or a return.
+type inflight struct {
+	msg         Message
+	attempt     int
+	deliveryTag uint64
+	deadline    time.Time
+}
+
+func (m inflight) messageID() string {
+	return m.msg.Publishing.MessageId
+}
+
+// parked is an inflight message waiting in the slot republish queue.
+// unparkedCh is closed once the message is taken out of the queue.
+type parked struct {
+	m          inflight
+	unparkedCh chan struct{}
+}
+
+// batch is a set of messages published in one publish state turn.
+// A transactional batch is committed or rolled back as one unit, the result is sent to resultCh.
+// It is rolled back if ctx is done before the commit.
+type batch struct {
+	ctx      context.Context
+	msgs     []Message
+	tx       bool
+	resultCh chan error
+}
+
 type Message struct {
 	Context      context.Context
 	Exchange     string
@@ -52,21 +134,35 @@ type Message struct {
 type Publisher struct {
 	connCh <-chan *Connection
 
-	ctx         context.Context
-	cancelFunc  context.CancelFunc
-	retryPeriod time.Duration
-	initFunc    func(conn AMQPConnection) (AMQPChannel, error)
-	logger      logger.Logger
+	ctx          context.Context
+	cancelFunc   context.CancelFunc
+	retryPeriod  time.Duration
+	backoff      backoff.Backoff
+	stablePeriod time.Duration
+	initFunc     func(conn AMQPConnection) (AMQPChannel, error)
+	logger       logger.Logger
 
 	mu       sync.Mutex
 	stateChs []chan State
 
 	confirmation       bool
 	confirmationBuffer uint
+	returnWait         time.Duration
+	transactions       bool
+	republish          bool
+	republishAttempts  int
+
+	messageIDPrefix string
+	messageIDSeq    uint64
+
+	channelPoolSize int
+	slots           []*slot
+	slotStateCh     chan slotState
 
 	closeCh chan struct{}
 
 	publishingCh chan Message
+	batchCh      chan batch
 
 	internalStateCh chan State
 }
@@ -79,8 +175,11 @@ func New(
 		connCh: connCh,
 
 		publishingCh:    make(chan Message),
+		batchCh:         make(chan batch),
 		closeCh:         make(chan struct{}),
 		internalStateCh: make(chan State),
+		slotStateCh:     make(chan slotState),
+		channelPoolSize: 1,
 	}
 
 	for _, opt := range opts {
@@ -97,6 +196,10 @@ func New(
 		p.retryPeriod = time.Second * 5
 	}
 
+	if p.backoff == nil {
+		p.backoff = backoff.Constant(p.retryPeriod)
+	}
+
 	for _, stateCh := range p.stateChs {
 		if stateCh == nil {
 			return nil, fmt.Errorf("state chan must be not nil")
@@ -115,6 +218,41 @@ func New(
 		return nil, fmt.Errorf("confirmation buffer size must be greater than 0")
 	}
 
+	if p.confirmation && p.transactions {
+		return nil, fmt.Errorf("confirmation and transactions could not be used together")
+	}
+
+	if p.republish && p.republishAttempts < 1 {
+		return nil, fmt.Errorf("republish attempts must be greater than 0")
+	}
+
+	if p.returnWait < 0 {
+		return nil, fmt.Errorf("return wait must be greater or equal to 0")
+	}
+
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	p.messageIDPrefix = hex.EncodeToString(prefix)
+
+	if p.channelPoolSize < 1 {
+		return nil, fmt.Errorf("channel pool size must be greater than 0")
+	}
+
+	for i := 0; i < p.channelPoolSize; i++ {
+		stateCh := p.internalStateCh
+		if p.channelPoolSize > 1 {
+			stateCh = make(chan State)
+		}
+
+		p.slots = append(p.slots, &slot{
+			id:      i,
+			stateCh: stateCh,
+			retry:   backoff.NewRetry(backoff.Clone(p.backoff), p.stablePeriod),
+		})
+	}
+
 	if p.initFunc == nil {
 		p.initFunc = func(conn AMQPConnection) (AMQPChannel, error) {
 			return conn.(*amqp.Connection).Channel()
@@ -141,6 +279,17 @@ func WithContext(ctx context.Context) Option {
 func WithRestartSleep(dur time.Duration) Option {
 	return func(p *Publisher) {
 		p.retryPeriod = dur
+		p.backoff = nil
+	}
+}
+
+// WithBackoff configure how much time to wait before the publisher retries to set up a channel.
+// The backoff is reset once the publisher stayed ready for at least stablePeriod.
+// Every channel of the pool gets its own clone of the backoff, see backoff.Cloner.
+func WithBackoff(b backoff.Backoff, stablePeriod time.Duration) Option {
+	return func(p *Publisher) {
+		p.backoff = b
+		p.stablePeriod = stablePeriod
 	}
 }
 
@@ -167,6 +316,49 @@ func WithConfirmation(buffer uint) Option {
 	}
 }
 
+// WithTransactions tells publisher to put channels into transaction mode.
+// Messages published with Publish, Go or PublishBatch are committed one by one,
+// PublishTx commits several messages as one unit.
+// The mode could not be used together with WithConfirmation.
+func WithTransactions() Option {
+	return func(p *Publisher) {
+		p.transactions = true
+	}
+}
+
+// WithReturnWait tells how long a mandatory message published without confirmation waits for a return.
+// By default returns are not waited for and the result is sent as soon as the message is published.
+// Without confirmation there is no way to know the message was routed, so the result is best effort:
+// it is sent to msg.ResultCh once the time is out and no return has come, a return that comes later is not reported.
+// With confirmation returns are always reported and the option is not used.
+func WithReturnWait(dur time.Duration) Option {
+	return func(p *Publisher) {
+		p.returnWait = dur
+	}
+}
+
+// WithRepublishOnReconnect tells publisher to keep messages that are in-flight when a channel or connection is closed,
+// and publish them again once the publisher is ready on a new channel.
+// A message is published at most maxAttempts more times, after that amqp.ErrClosed is sent to msg.ResultCh.
+// Messages whose context is done are not published again, the context error is sent instead.
+// A message is in-flight until it is confirmed, so the option is mostly useful together with WithConfirmation.
+func WithRepublishOnReconnect(maxAttempts int) Option {
+	return func(p *Publisher) {
+		p.republishAttempts = maxAttempts
+		p.republish = true
+	}
+}
+
+// WithChannelPoolSize tells publisher how many channels to open on a connection. Default: 1.
+// Messages are spread across the channels, so they could be published in parallel.
+// Each channel has its own confirmation buffer, flow control and reconnection.
+// The publisher is ready while at least one of the channels is ready.
+func WithChannelPoolSize(size int) Option {
+	return func(p *Publisher) {
+		p.channelPoolSize = size
+	}
+}
+
 func (p *Publisher) Notify(stateCh chan State) <-chan State {
 	if cap(stateCh) == 0 {
 		panic("state chan is unbuffered")
@@ -243,10 +435,115 @@ func (p *Publisher) NotifyClosed() <-chan struct{} {
 	return p.closeCh
 }
 
+// Confirmation tells whether the publisher is created with WithConfirmation option,
+// so a nil result means the message is confirmed by the broker.
+func (p *Publisher) Confirmation() bool {
+	return p.confirmation
+}
+
+// PublishBatch publishes messages on one channel one after another, nothing else is published on the channel in between.
+// It waits for all the results and returns them in the order of the messages.
+// Each message gets its own result channel, msg.ResultCh is not used.
+// A message without a context gets ctx as its context.
+// If ctx is done before a message result comes, the result is the ctx error.
+func (p *Publisher) PublishBatch(ctx context.Context, msgs []Message) []error {
+	results := make([]error, len(msgs))
+	if len(msgs) == 0 {
+		return results
+	}
+
+	b := batch{msgs: make([]Message, len(msgs))}
+	for i, msg := range msgs {
+		msg.ResultCh = make(chan error, 1)
+		if msg.Context == nil {
+			msg.Context = ctx
+		}
+
+		b.msgs[i] = msg
+	}
+
+	if err := p.goBatch(ctx, b); err != nil {
+		for i := range results {
+			results[i] = err
+		}
+
+		return results
+	}
+
+	for i, msg := range b.msgs {
+		select {
+		case results[i] = <-msg.ResultCh:
+		case <-ctx.Done():
+			results[i] = fmt.Errorf("batch: %v", ctx.Err())
+		}
+	}
+
+	return results
+}
+
+// PublishTx publishes messages in a transaction, they are either all committed or rolled back.
+// The publisher must be created with WithTransactions option.
+// If the channel is closed in the middle of the transaction, the messages are not committed and an error is returned.
+// If ctx is done before the commit, the transaction is rolled back.
+// Once the transaction is started, PublishTx waits for it to be committed or rolled back even if ctx is done meanwhile.
+func (p *Publisher) PublishTx(ctx context.Context, msgs []Message) error {
+	if !p.transactions {
+		return fmt.Errorf("transactions are not enabled")
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	b := batch{ctx: ctx, msgs: msgs, tx: true, resultCh: make(chan error, 1)}
+	if err := p.goBatch(ctx, b); err != nil {
+		return err
+	}
+
+	// the batch is settled by publishState, it always sends the result.
+	return <-b.resultCh
+}
+
+func (p *Publisher) goBatch(ctx context.Context, b batch) error {
+	var stateCh <-chan State
+	for _, msg := range b.msgs {
+		if msg.ErrOnUnready {
+			stateCh = p.internalStateCh
+		}
+	}
+	select {
+	case <-p.closeCh:
+		return fmt.Errorf("publisher stopped")
+	default:
+	}
+
+	for {
+		select {
+		case p.batchCh <- b:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("batch: %v", ctx.Err())
+		// noinspection GoNilness
+		case state := <-stateCh:
+			if state.Unready != nil {
+				return fmt.Errorf("publisher not ready")
+			}
+		case <-p.ctx.Done():
+			return fmt.Errorf("publisher stopped")
+		}
+	}
+}
+
 func (p *Publisher) connectionState() {
 	defer p.cancelFunc()
 	defer close(p.closeCh)
 	defer p.logger.Printf("[DEBUG] publisher stopped")
+	defer func() {
+		for _, s := range p.slots {
+			for _, m := range s.unparkAll() {
+				m.msg.ResultCh <- amqp.ErrClosed
+			}
+		}
+	}()
 
 	p.logger.Printf("[DEBUG] publisher starting")
 	state := State{Unready: &Unready{Err: amqp.ErrClosed}}
@@ -264,7 +561,12 @@ func (p *Publisher) connectionState() {
 				return
 			default:
 			}
-			err := p.channelState(conn.AMQPConnection(), conn.NotifyClose())
+			var err error
+			if len(p.slots) > 1 {
+				err = p.channelPoolState(conn.AMQPConnection(), conn.NotifyClose())
+			} else {
+				err = p.channelState(p.slots[0], conn.AMQPConnection(), conn.NotifyClose())
+			}
 			if err != nil {
 				p.logger.Printf("[DEBUG] publisher unready")
 				state = State{Unready: &Unready{err}}
@@ -279,60 +581,143 @@ func (p *Publisher) connectionState() {
 	}
 }
 
-func (p *Publisher) channelState(conn AMQPConnection, connCloseCh <-chan struct{}) error {
+// channelPoolState runs a channel state for every slot of the pool on the given connection.
+// A slot which failed to set up its channel is restarted while the connection is alive.
+// It serves Publisher.Notify() and notifies subscribers once the first slot is ready or the last one is unready.
+func (p *Publisher) channelPoolState(conn AMQPConnection, connCloseCh <-chan struct{}) error {
+	doneCh := make(chan slotState)
+	running := 0
+	start := func(s *slot) {
+		running++
+		go func() {
+			doneCh <- slotState{slot: s, err: p.channelState(s, conn, connCloseCh)}
+		}()
+	}
+
+	for _, s := range p.slots {
+		start(s)
+	}
+
+	ready := make([]bool, len(p.slots))
+	readyNum := 0
+	state := State{Unready: &Unready{Err: amqp.ErrClosed}}
+	var result error
+	for running > 0 {
+		select {
+		case p.internalStateCh <- state:
+			continue
+		case ss := <-p.slotStateCh:
+			if ss.ready != ready[ss.slot.id] {
+				ready[ss.slot.id] = ss.ready
+				if ss.ready {
+					readyNum++
+				} else {
+					readyNum--
+				}
+
+				if ss.ready && readyNum == 1 {
+					state = p.notifyReady()
+				}
+			}
+
+			if !ss.ready && readyNum == 0 {
+				state = p.notifyUnready(ss.err)
+			}
+		case ss := <-doneCh:
+			running--
+			if ss.err == nil {
+				continue
+			}
+
+			result = ss.err
+
+			select {
+			case <-connCloseCh:
+				continue
+			case <-p.ctx.Done():
+				continue
+			default:
+			}
+
+			start(ss.slot)
+		}
+	}
+
+	if p.ctx.Err() != nil {
+		return nil
+	}
+
+	return result
+}
+
+func (p *Publisher) channelState(s *slot, conn AMQPConnection, connCloseCh <-chan struct{}) error {
 	for {
 		ch, err := p.initFunc(conn)
 		if err != nil {
 			p.logger.Printf("[ERROR] init func: %s", err)
-			return p.waitRetry(err)
+			return p.waitRetry(s, err)
 		}
 
-		var resultChCh chan chan error
+		var inflightCh chan inflight
+
+		returnCh := ch.NotifyReturn(make(chan amqp.Return, 1))
 
-		confirmationCloseCh := make(chan struct{})
-		confirmationDoneCh := make(chan struct{})
+		if p.transactions {
+			err = ch.Tx()
+			if err != nil {
+				return p.waitRetry(s, err)
+			}
+		}
+
+		handlerCloseCh := make(chan struct{})
+		handlerDoneCh := make(chan struct{})
 		if p.confirmation {
 			err = ch.Confirm(false)
 			if err != nil {
-				return p.waitRetry(err)
+				return p.waitRetry(s, err)
 			}
 
+			s.deliveryTag = 0
 			confirmationCh := ch.NotifyPublish(make(chan amqp.Confirmation, p.confirmationBuffer))
 
-			resultChCh = make(chan chan error, p.confirmationBuffer)
+			inflightCh = make(chan inflight, p.confirmationBuffer)
 
-			go p.handleConfirmations(resultChCh, confirmationCh, confirmationCloseCh, confirmationDoneCh)
+			go p.handleConfirmations(s, inflightCh, confirmationCh, returnCh, handlerCloseCh, handlerDoneCh)
 		} else {
-			close(confirmationDoneCh)
+			inflightCh = make(chan inflight)
+
+			go p.handleReturns(s, inflightCh, returnCh, handlerCloseCh, handlerDoneCh)
 		}
 
-		err = p.publishState(ch, connCloseCh, resultChCh)
-		close(confirmationCloseCh)
+		err = p.publishState(s, ch, connCloseCh, inflightCh)
+		close(handlerCloseCh)
 		if err == errChannelClosed {
-			<-confirmationDoneCh
+			<-handlerDoneCh
 			continue
 		}
 		if err != nil {
-			p.notifyUnready(err)
+			p.slotUnready(s, err)
 		}
 
 		p.close(ch)
-		<-confirmationDoneCh
+		<-handlerDoneCh
 
 		return err
 	}
 }
 
 func (p *Publisher) handleConfirmations(
-	resultChCh chan chan error,
+	s *slot,
+	inflightCh chan inflight,
 	confirmationCh chan amqp.Confirmation,
+	returnCh chan amqp.Return,
 	confirmationCloseCh,
 	confirmationDoneCh chan struct{},
 ) {
 	defer close(confirmationDoneCh)
 
 	select {
-	case state := <-p.internalStateCh:
+	case state := <-s.stateCh:
 		if state.Unready != nil {
 			p.logger.Printf("[ERROR] handle confirmation unexpected unready")
 			return
@@ -340,12 +725,56 @@ func (p *Publisher) handleConfirmations(
 
 		p.logger.Printf("[DEBUG] handle confirmation ready")
 	case <-confirmationCloseCh:
+		p.closeConfirmations(s, inflightCh, nil)
 		return
 	}
 
 	p.logger.Printf("[DEBUG] handle confirmation started")
 	defer p.logger.Printf("[DEBUG] handle confirmation stopped")
 
+	// The server sends a return before the confirmation of the same message.
+	returned := make(map[string]amqp.Return)
+	drainReturns := func() {
+		for {
+			select {
+			case r, ok := <-returnCh:
+				if !ok {
+					returnCh = nil
+					return
+				}
+				if r.MessageId != "" {
+					returned[r.MessageId] = r
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	// The channel delivers a confirmation per delivery tag, a multiple ack or nack comes as several confirmations.
+	// They are correlated by the tag so the order they come in does not matter.
+	pending := make(map[uint64]inflight)
+	var lastTag uint64
+	waitInflight := func(tag uint64) (inflight, bool) {
+		for {
+			if m, ok := pending[tag]; ok {
+				delete(pending, tag)
+				return m, true
+			}
+			if tag <= lastTag {
+				return inflight{}, false
+			}
+
+			select {
+			case m := <-inflightCh:
+				pending[m.deliveryTag] = m
+				lastTag = m.deliveryTag
+			case <-p.ctx.Done():
+				return inflight{}, false
+			}
+		}
+	}
+
 loop:
 	for {
 		select {
@@ -354,42 +783,201 @@ loop:
 				break loop
 			}
 
-			resultCh := <-resultChCh
-			if c.Ack {
-				resultCh <- nil
-			} else {
-				resultCh <- fmt.Errorf("confirmation: nack")
+			drainReturns()
+
+			m, ok := waitInflight(c.DeliveryTag)
+			if !ok {
+				p.logger.Printf("[WARN] handle confirmation: unexpected delivery tag %d", c.DeliveryTag)
+				continue
+			}
+
+			r, isReturned := returned[m.messageID()]
+			if isReturned {
+				delete(returned, m.messageID())
+			}
+
+			switch {
+			case !c.Ack:
+				m.msg.ResultCh <- fmt.Errorf("confirmation: nack")
+			case isReturned && m.messageID() != "":
+				m.msg.ResultCh <- newErrReturned(r)
+			default:
+				m.msg.ResultCh <- nil
 			}
 
 			continue
+		case r, ok := <-returnCh:
+			if !ok {
+				returnCh = nil
+				continue
+			}
+			if r.MessageId != "" {
+				returned[r.MessageId] = r
+			}
 		case <-confirmationCloseCh:
 			break loop
 		}
 	}
 	<-confirmationCloseCh
+	p.closeConfirmations(s, inflightCh, pending)
+}
+
+// closeConfirmations closes messages left unconfirmed, in the order they were published.
+func (p *Publisher) closeConfirmations(s *slot, inflightCh chan inflight, pending map[uint64]inflight) {
+	if pending == nil {
+		pending = make(map[uint64]inflight)
+	}
+
+drain:
 	for {
 		select {
-		case resultCh := <-resultChCh:
-			resultCh <- amqp.ErrClosed
-			continue
+		case m := <-inflightCh:
+			pending[m.deliveryTag] = m
 		default:
-			return
+			break drain
 		}
 	}
+
+	tags := make([]uint64, 0, len(pending))
+	for tag := range pending {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	for _, tag := range tags {
+		p.closeInflight(s, pending[tag])
+	}
 }
 
-func (p *Publisher) publishState(ch AMQPChannel, connCloseCh <-chan struct{}, resultChCh chan chan error) error {
+// handleReturns waits for returns of mandatory messages published without confirmation.
+// A message is considered routed if no return has come within the return wait period.
+// With no return wait it only drains returnCh.
+func (p *Publisher) handleReturns(
+	s *slot,
+	inflightCh chan inflight,
+	returnCh chan amqp.Return,
+	closeCh,
+	doneCh chan struct{},
+) {
+	defer close(doneCh)
+
+	var pending []inflight
+
+	// A return may come before the message is handed over by publish, keep it around for the return wait period.
+	type early struct {
+		r      amqp.Return
+		expire time.Time
+	}
+	returned := make(map[string]early)
+
+	timer := time.NewTimer(time.Hour)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+	timerSet := false
+
+loop:
+	for {
+		if timerSet && !timer.Stop() {
+			<-timer.C
+		}
+		timerSet = false
+
+		var timerCh <-chan time.Time
+		if len(pending) > 0 {
+			timer.Reset(time.Until(pending[0].deadline))
+			timerCh = timer.C
+			timerSet = true
+		}
+
+		select {
+		case m := <-inflightCh:
+			if e, ok := returned[m.messageID()]; ok {
+				delete(returned, m.messageID())
+				m.msg.ResultCh <- newErrReturned(e.r)
+				continue
+			}
+
+			pending = append(pending, m)
+		case r, ok := <-returnCh:
+			if !ok {
+				returnCh = nil
+				continue
+			}
+			if r.MessageId == "" {
+				continue
+			}
+
+			now := time.Now()
+			for id, e := range returned {
+				if !e.expire.After(now) {
+					delete(returned, id)
+				}
+			}
+
+			matched := false
+			for i, m := range pending {
+				if m.messageID() == r.MessageId {
+					m.msg.ResultCh <- newErrReturned(r)
+					pending = append(pending[:i], pending[i+1:]...)
+					matched = true
+					break
+				}
+			}
+			if !matched && p.returnWait > 0 {
+				returned[r.MessageId] = early{r: r, expire: now.Add(p.returnWait)}
+			}
+		case <-timerCh:
+			timerSet = false
+
+			now := time.Now()
+			for len(pending) > 0 && !pending[0].deadline.After(now) {
+				pending[0].msg.ResultCh <- nil
+				pending = pending[1:]
+			}
+		case <-closeCh:
+			break loop
+		}
+	}
+
+	for _, m := range pending {
+		p.closeInflight(s, m)
+	}
+}
+
+func (p *Publisher) publishState(s *slot, ch AMQPChannel, connCloseCh <-chan struct{}, inflightCh chan inflight) error {
 	chCloseCh := ch.NotifyClose(make(chan *amqp.Error, 1))
 	chFlowCh := ch.NotifyFlow(make(chan bool, 1))
 
 	p.logger.Printf("[DEBUG] publisher ready")
-	state := p.notifyReady()
+	s.retry.Ready()
+	state := p.slotReady(s)
+
+	if p.confirmation {
+		// handleConfirmations drains inflightCh only once it got the state,
+		// so it must get it before more messages than the confirmation buffer holds are published.
+		s.stateCh <- state
+	}
+
+	for _, m := range s.unparkAll() {
+		p.publish(s, ch, m.msg, m.attempt+1, inflightCh)
+	}
+
 	for {
 		select {
-		case p.internalStateCh <- state:
+		case s.stateCh <- state:
 			continue
 		case msg := <-p.publishingCh:
-			p.publish(ch, msg, resultChCh)
+			p.publish(s, ch, msg, 0, inflightCh)
+		case b := <-p.batchCh:
+			if b.tx {
+				b.resultCh <- p.publishTx(ch, b)
+				continue
+			}
+
+			for _, msg := range b.msgs {
+				p.publish(s, ch, msg, 0, inflightCh)
+			}
 		case <-chCloseCh:
 			p.logger.Printf("[DEBUG] channel closed")
 			return errChannelClosed
@@ -399,23 +987,23 @@ func (p *Publisher) publishState(ch AMQPChannel, connCloseCh <-chan struct{}, re
 			if resume {
 				continue
 			}
-			if err := p.pausedState(chFlowCh, connCloseCh, chCloseCh); err != nil {
+			if err := p.pausedState(s, chFlowCh, connCloseCh, chCloseCh); err != nil {
 				return err
 			}
-			state = p.notifyReady()
+			state = p.slotReady(s)
 		case <-p.ctx.Done():
 			return nil
 		}
 	}
 }
 
-func (p *Publisher) pausedState(chFlowCh <-chan bool, connCloseCh <-chan struct{}, chCloseCh chan *amqp.Error) error {
+func (p *Publisher) pausedState(s *slot, chFlowCh <-chan bool, connCloseCh <-chan struct{}, chCloseCh chan *amqp.Error) error {
 	p.logger.Printf("[WARN] publisher flow paused")
 	errFlowPaused := fmt.Errorf("publisher flow paused")
-	state := p.notifyUnready(errFlowPaused)
+	state := p.slotUnready(s, errFlowPaused)
 	for {
 		select {
-		case p.internalStateCh <- state:
+		case s.stateCh <- state:
 			continue
 		case resume := <-chFlowCh:
 			if resume {
@@ -433,13 +1021,18 @@ func (p *Publisher) pausedState(chFlowCh <-chan bool, connCloseCh <-chan struct{
 	}
 }
 
-func (p *Publisher) publish(ch AMQPChannel, msg Message, resultChCh chan chan error) {
+func (p *Publisher) publish(s *slot, ch AMQPChannel, msg Message, attempt int, inflightCh chan inflight) {
 	select {
 	case <-msg.Context.Done():
 		msg.ResultCh <- fmt.Errorf("message: %v", msg.Context.Err())
+		return
 	default:
 	}
 
+	if msg.Mandatory && msg.Publishing.MessageId == "" {
+		msg.Publishing.MessageId = p.nextMessageID()
+	}
+
 	result := ch.Publish(
 		msg.Exchange,
 		msg.Key,
@@ -447,10 +1040,8 @@ func (p *Publisher) publish(ch AMQPChannel, msg Message, resultChCh chan chan er
 		msg.Immediate,
 		msg.Publishing,
 	)
-
-	if !p.confirmation {
-		msg.ResultCh <- result
-		return
+	if result == nil && p.transactions {
+		result = ch.TxCommit()
 	}
 
 	if result != nil {
@@ -458,27 +1049,159 @@ func (p *Publisher) publish(ch AMQPChannel, msg Message, resultChCh chan chan er
 		return
 	}
 
+	m := inflight{msg: msg, attempt: attempt}
+	if p.confirmation {
+		s.deliveryTag++
+		m.deliveryTag = s.deliveryTag
+	} else {
+		if !msg.Mandatory || p.returnWait == 0 {
+			msg.ResultCh <- nil
+			return
+		}
+
+		m.deadline = time.Now().Add(p.returnWait)
+	}
+
 	select {
-	case resultChCh <- msg.ResultCh:
+	case inflightCh <- m:
 	case <-p.ctx.Done():
 		msg.ResultCh <- p.ctx.Err()
 		return
 	}
 }
 
-func (p *Publisher) waitRetry(err error) error {
-	timer := time.NewTimer(p.retryPeriod)
-	defer func() {
-		timer.Stop()
+func (p *Publisher) publishTx(ch AMQPChannel, b batch) error {
+	for _, msg := range b.msgs {
 		select {
-		case <-timer.C:
+		case <-b.ctx.Done():
+			return p.rollback(ch, fmt.Errorf("tx: %v", b.ctx.Err()))
 		default:
 		}
+
+		if msg.Context != nil {
+			select {
+			case <-msg.Context.Done():
+				return p.rollback(ch, fmt.Errorf("tx: message: %v", msg.Context.Err()))
+			default:
+			}
+		}
+
+		err := ch.Publish(
+			msg.Exchange,
+			msg.Key,
+			msg.Mandatory,
+			msg.Immediate,
+			msg.Publishing,
+		)
+		if err != nil {
+			return p.rollback(ch, fmt.Errorf("tx: publish: %v", err))
+		}
+	}
+
+	select {
+	case <-b.ctx.Done():
+		return p.rollback(ch, fmt.Errorf("tx: %v", b.ctx.Err()))
+	default:
+	}
+
+	if err := ch.TxCommit(); err != nil {
+		return fmt.Errorf("tx: commit: %v", err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) rollback(ch AMQPChannel, err error) error {
+	if rollbackErr := ch.TxRollback(); rollbackErr != nil {
+		return fmt.Errorf("%v: rollback: %v", err, rollbackErr)
+	}
+
+	return err
+}
+
+// closeInflight is called for a message that is still in-flight when the channel is closed.
+// The message is kept for republishing if WithRepublishOnReconnect is set and attempts are left.
+func (p *Publisher) closeInflight(s *slot, m inflight) {
+	if !p.republish || m.attempt >= p.republishAttempts {
+		m.msg.ResultCh <- amqp.ErrClosed
+		return
+	}
+
+	select {
+	case <-p.ctx.Done():
+		m.msg.ResultCh <- amqp.ErrClosed
+		return
+	case <-m.msg.Context.Done():
+		m.msg.ResultCh <- fmt.Errorf("message: %v", m.msg.Context.Err())
+		return
+	default:
+	}
+
+	s.park(m)
+}
+
+// park puts the message to the republish queue.
+// The message is dropped from the queue with the context error as soon as its context is done.
+func (s *slot) park(m inflight) {
+	pm := &parked{m: m, unparkedCh: make(chan struct{})}
+
+	s.republishMu.Lock()
+	s.republish = append(s.republish, pm)
+	s.republishMu.Unlock()
+
+	go func() {
+		select {
+		case <-m.msg.Context.Done():
+			if s.unpark(pm) {
+				m.msg.ResultCh <- fmt.Errorf("message: %v", m.msg.Context.Err())
+			}
+		case <-pm.unparkedCh:
+		}
 	}()
-	state := p.notifyUnready(err)
+}
+
+// unpark removes the message from the republish queue, it returns false if the message has already been taken out.
+func (s *slot) unpark(pm *parked) bool {
+	s.republishMu.Lock()
+	defer s.republishMu.Unlock()
+
+	for i, other := range s.republish {
+		if other == pm {
+			s.republish = append(s.republish[:i], s.republish[i+1:]...)
+			close(pm.unparkedCh)
+			return true
+		}
+	}
+
+	return false
+}
+
+// unparkAll empties the republish queue and returns its messages in the order they were published.
+func (s *slot) unparkAll() []inflight {
+	s.republishMu.Lock()
+	defer s.republishMu.Unlock()
+
+	msgs := make([]inflight, 0, len(s.republish))
+	for _, pm := range s.republish {
+		close(pm.unparkedCh)
+		msgs = append(msgs, pm.m)
+	}
+	s.republish = nil
+
+	return msgs
+}
+
+func (p *Publisher) nextMessageID() string {
+	return fmt.Sprintf("%s-%d", p.messageIDPrefix, atomic.AddUint64(&p.messageIDSeq, 1))
+}
+
+func (p *Publisher) waitRetry(s *slot, err error) error {
+	timer := s.retry.Timer()
+	defer backoff.Stop(timer)
+	state := p.slotUnready(s, err)
 	for {
 		select {
-		case p.internalStateCh <- state:
+		case s.stateCh <- state:
 			continue
 		case <-timer.C:
 			return err
@@ -488,6 +1211,26 @@ func (p *Publisher) waitRetry(err error) error {
 	}
 }
 
+// slotReady notifies subscribers directly if there is only one slot.
+// Otherwise the state is passed to Publisher.channelPoolState() which notifies subscribers.
+func (p *Publisher) slotReady(s *slot) State {
+	if len(p.slots) == 1 {
+		return p.notifyReady()
+	}
+
+	p.slotStateCh <- slotState{slot: s, ready: true}
+	return State{Ready: &Ready{}}
+}
+
+func (p *Publisher) slotUnready(s *slot, err error) State {
+	if len(p.slots) == 1 {
+		return p.notifyUnready(err)
+	}
+
+	p.slotStateCh <- slotState{slot: s, err: err}
+	return State{Unready: &Unready{Err: err}}
+}
+
 func (p *Publisher) notifyUnready(err error) State {
 	state := State{Unready: &Unready{Err: err}}
 	p.mu.Lock()
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -75,6 +78,7 @@ func TestNotify(main *testing.T) {
 
 		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		ch.EXPECT().NotifyClose(any()).Times(1)
 		ch.EXPECT().NotifyFlow(any()).Times(1)
@@ -159,6 +163,7 @@ func TestNotify(main *testing.T) {
 
 		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		ch.
 			EXPECT().
@@ -214,6 +219,7 @@ func TestNotify(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		ch.
 			EXPECT().
@@ -248,6 +254,7 @@ func TestReconnection(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -343,6 +350,7 @@ func TestReconnection(main *testing.T) {
 		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -363,6 +371,7 @@ func TestReconnection(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		newCh := mock_publisher.NewMockAMQPChannel(ctrl)
+		newCh.EXPECT().NotifyReturn(any()).AnyTimes()
 		newCh.
 			EXPECT().
 			NotifyClose(any()).
@@ -418,6 +427,7 @@ func TestReconnection(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -475,6 +485,7 @@ func TestReconnection(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -490,6 +501,7 @@ func TestReconnection(main *testing.T) {
 			Times(1)
 
 		newCh := mock_publisher.NewMockAMQPChannel(ctrl)
+		newCh.EXPECT().NotifyReturn(any()).AnyTimes()
 		newCh.
 			EXPECT().
 			NotifyClose(any()).
@@ -543,6 +555,7 @@ func TestReconnection(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -557,6 +570,7 @@ func TestReconnection(main *testing.T) {
 		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
 
 		newCh := mock_publisher.NewMockAMQPChannel(ctrl)
+		newCh.EXPECT().NotifyReturn(any()).AnyTimes()
 		newCh.
 			EXPECT().
 			NotifyClose(any()).
@@ -614,6 +628,7 @@ func TestUnreadyReady(main *testing.T) {
 
 		ctrl := gomock.NewController(t)
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		conn := mock_publisher.NewMockAMQPConnection(ctrl)
 
 		ch.
@@ -736,6 +751,7 @@ func TestUnreadyReady(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		stateCh := make(chan publisher.State, 2)
 
 		connCh, l, p := newPublisher(
@@ -780,6 +796,7 @@ func TestReadyPublisher(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -864,7 +881,7 @@ func TestReadyPublisher(main *testing.T) {
 			},
 		})
 
-		err := waitResult(resultCh, time.Millisecond*100)
+		err := waitResult(resultCh, time.Millisecond*200)
 		require.NoError(t, err)
 		p.Close()
 		assertClosed(t, p)
@@ -883,6 +900,7 @@ func TestReadyPublisher(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -942,6 +960,7 @@ func TestReadyPublisher(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1002,6 +1021,7 @@ func TestReadyPublisher(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1067,6 +1087,7 @@ func TestReadyPublisher(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1245,6 +1266,7 @@ func TestClose(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1295,6 +1317,7 @@ func TestClose(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1343,6 +1366,7 @@ func TestClose(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1392,6 +1416,7 @@ func TestConcurrency(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1457,6 +1482,7 @@ func TestConcurrency(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1486,6 +1512,7 @@ func TestConcurrency(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		newCh := mock_publisher.NewMockAMQPChannel(ctrl)
+		newCh.EXPECT().NotifyReturn(any()).AnyTimes()
 		newCh.
 			EXPECT().
 			NotifyClose(any()).
@@ -1560,6 +1587,7 @@ func TestConcurrency(main *testing.T) {
 		chCloseCh := make(chan *amqp.Error)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1581,6 +1609,7 @@ func TestConcurrency(main *testing.T) {
 			MaxTimes(40)
 
 		newCh := mock_publisher.NewMockAMQPChannel(ctrl)
+		newCh.EXPECT().NotifyReturn(any()).AnyTimes()
 		newCh.
 			EXPECT().
 			NotifyClose(any()).
@@ -1656,6 +1685,7 @@ func TestFlowControl(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1731,6 +1761,7 @@ func TestFlowControl(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1815,6 +1846,7 @@ func TestFlowControl(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1894,6 +1926,7 @@ func TestFlowControl(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -1948,6 +1981,7 @@ func TestFlowControl(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -2013,6 +2047,7 @@ func TestFlowControl(main *testing.T) {
 		stateCh := make(chan publisher.State, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.
 			EXPECT().
 			NotifyClose(any()).
@@ -2087,6 +2122,7 @@ func TestPublisherConfirms(main *testing.T) {
 		defer ctrl.Finish()
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 		ch.EXPECT().Confirm(false).Return(fmt.Errorf("the error")).Times(1)
 		ch.EXPECT().Close().Times(1)
 		defer ch.Close()
@@ -2125,6 +2161,7 @@ func TestPublisherConfirms(main *testing.T) {
 		confirmationCh := make(chan amqp.Confirmation, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		ch.EXPECT().
 			NotifyPublish(any()).
@@ -2159,8 +2196,8 @@ func TestPublisherConfirms(main *testing.T) {
 		p.Go(publisher.Message{ResultCh: resultCh})
 		p.Go(publisher.Message{ResultCh: resultCh})
 
-		confirmationCh <- amqp.Confirmation{Ack: true}
-		confirmationCh <- amqp.Confirmation{Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
 
 		require.NoError(t, waitResult(resultCh, time.Millisecond*100))
 		require.NoError(t, waitResult(resultCh, time.Millisecond*100))
@@ -2187,6 +2224,7 @@ func TestPublisherConfirms(main *testing.T) {
 		confirmationCh := make(chan amqp.Confirmation, 2)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		ch.EXPECT().
 			NotifyPublish(any()).
@@ -2222,8 +2260,8 @@ func TestPublisherConfirms(main *testing.T) {
 		p.Go(publisher.Message{ResultCh: resultCh})
 		p.Go(publisher.Message{ResultCh: resultCh})
 
-		confirmationCh <- amqp.Confirmation{Ack: false}
-		confirmationCh <- amqp.Confirmation{Ack: false}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
 
 		require.EqualError(t, waitResult(resultCh, time.Millisecond*100), "confirmation: nack")
 		require.EqualError(t, waitResult(resultCh, time.Millisecond*100), "confirmation: nack")
@@ -2251,6 +2289,7 @@ func TestPublisherConfirms(main *testing.T) {
 		confirmationCh := make(chan amqp.Confirmation, 4)
 
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		ch.EXPECT().
 			NotifyPublish(any()).
@@ -2288,8 +2327,8 @@ func TestPublisherConfirms(main *testing.T) {
 		p.Go(publisher.Message{ResultCh: resultCh})
 		p.Go(publisher.Message{ResultCh: resultCh})
 
-		confirmationCh <- amqp.Confirmation{Ack: true}
-		confirmationCh <- amqp.Confirmation{Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
 
 		time.Sleep(time.Millisecond * 100)
 		close(connCloseCh)
@@ -2323,6 +2362,7 @@ func TestPublisherConfirms(main *testing.T) {
 		confirmationCh := make(chan amqp.Confirmation, 4)
 		chCloseCh := make(chan *amqp.Error, 1)
 		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
 		secondConfirmationCh := make(chan amqp.Confirmation, 4)
 		secondChCloseCh := make(chan *amqp.Error, 1)
@@ -2364,8 +2404,8 @@ func TestPublisherConfirms(main *testing.T) {
 		p.Go(publisher.Message{ResultCh: resultCh})
 		p.Go(publisher.Message{ResultCh: resultCh})
 
-		confirmationCh <- amqp.Confirmation{Ack: true}
-		confirmationCh <- amqp.Confirmation{Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
 
 		time.Sleep(time.Millisecond * 100)
 
@@ -2394,126 +2434,1558 @@ func TestPublisherConfirms(main *testing.T) {
 `
 		require.Equal(t, expected, l.Logs())
 	})
-}
 
-func assertClosed(t *testing.T, p *publisher.Publisher) {
-	timer := time.NewTimer(time.Millisecond * 100)
-	defer timer.Stop()
+	main.Run("ConfirmsCorrelatedByDeliveryTag", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
 
-	select {
-	case <-p.NotifyClosed():
-	case <-timer.C:
-		t.Fatal("publisher close timeout")
-	}
-}
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
 
-func assertUnready(t *testing.T, stateCh <-chan publisher.State, errString string) {
-	timer := time.NewTimer(time.Millisecond * 100)
-	defer timer.Stop()
+		confirmationCh := make(chan amqp.Confirmation, 3)
 
-	select {
-	case state := <-stateCh:
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
-		require.Nil(t, state.Ready, fmt.Sprintf("%+v", state))
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(confirmationCh)).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			Return(nil).
+			Times(3)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
 
-		require.NotNil(t, state.Unready, fmt.Sprintf("%+v", state))
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil)
+		ch.EXPECT().Close().AnyTimes()
 
-		require.EqualError(t, state.Unready.Err, errString)
-	case <-timer.C:
-		t.Fatal("publisher must be unready")
-	}
-}
+		stateCh := make(chan publisher.State, 2)
 
-func assertReady(t *testing.T, stateCh <-chan publisher.State) {
-	timer := time.NewTimer(time.Millisecond * 100)
-	defer timer.Stop()
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(3),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
 
-	select {
-	case state := <-stateCh:
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
 
-		require.Nil(t, state.Unready, fmt.Sprintf("%+v", state))
+		firstResultCh := p.Go(publisher.Message{})
+		secondResultCh := p.Go(publisher.Message{})
+		thirdResultCh := p.Go(publisher.Message{})
 
-		require.NotNil(t, state.Ready, fmt.Sprintf("%+v", state))
-	case <-timer.C:
-		t.Fatal("publisher must be ready")
-	}
-}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
 
-func assertNoStateChanged(t *testing.T, stateCh <-chan publisher.State) {
-	timer := time.NewTimer(time.Millisecond * 100)
-	defer timer.Stop()
-	select {
-	case <-stateCh:
-		t.Fatal("state change is not expected")
-	case <-timer.C:
-	}
-}
+		require.NoError(t, waitResult(firstResultCh, time.Millisecond*100))
+		require.EqualError(t, waitResult(secondResultCh, time.Millisecond*100), "confirmation: nack")
+		require.NoError(t, waitResult(thirdResultCh, time.Millisecond*100))
 
-func any() gomock.Matcher {
-	return gomock.Any()
-}
+		close(confirmationCh)
+		p.Close()
+		assertClosed(t, p)
+	})
 
-func newPublisher(opts ...publisher.Option) (connCh chan *publisher.Connection, l *logger.TestLogger, p *publisher.Publisher) {
-	connCh = make(chan *publisher.Connection, 1)
+	main.Run("DeliveryTagResetOnChannelReopen", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
 
-	l = logger.NewTest()
-	opts = append(opts, publisher.WithLogger(l))
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
 
-	p, err := publisher.New(connCh, opts...)
-	if err != nil {
-		panic(err)
-	}
+		confirmationCh := make(chan amqp.Confirmation, 2)
+		chCloseCh := make(chan *amqp.Error, 1)
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
 
-	return connCh, l, p
-}
+		secondConfirmationCh := make(chan amqp.Confirmation, 2)
+		secondChCloseCh := make(chan *amqp.Error, 1)
 
-func waitResult(resultCh <-chan error, dur time.Duration) error {
-	timer := time.NewTimer(dur)
-	defer timer.Stop()
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(confirmationCh, secondConfirmationCh)).
+			Times(2)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			Return(nil).
+			Times(3)
+		ch.EXPECT().
+			NotifyClose(any()).
+			DoAndReturn(chCloseChStub(chCloseCh, secondChCloseCh)).
+			AnyTimes()
 
-	select {
-	case res := <-resultCh:
-		return res
-	case <-timer.C:
-		return fmt.Errorf("wait result timeout")
-	}
-}
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
 
-func notifyCloseStub() func(_ chan *amqp.Error) chan *amqp.Error {
-	return func(ch chan *amqp.Error) chan *amqp.Error {
-		return ch
-	}
-}
+		stateCh := make(chan publisher.State, 2)
 
-func notifyFlowStub() func(_ chan bool) chan bool {
-	return func(ch chan bool) chan bool {
-		return ch
-	}
-}
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(2),
+			publisher.WithInitFunc(initFuncStub(ch, ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
 
-func confirmationChStub(chs ...interface{}) func(chan amqp.Confirmation) chan amqp.Confirmation {
-	index := 0
-	return func(ch chan amqp.Confirmation) chan amqp.Confirmation {
-		switch curr := chs[index].(type) {
-		case chan amqp.Confirmation:
-			index++
-			return curr
-		default:
-			panic(fmt.Sprintf("unexpected type given: %T", chs[index]))
-		}
-	}
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		require.NoError(t, func() error {
+			resultCh := p.Go(publisher.Message{})
+			confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+			return waitResult(resultCh, time.Millisecond*100)
+		}())
+		require.NoError(t, func() error {
+			resultCh := p.Go(publisher.Message{})
+			confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+			return waitResult(resultCh, time.Millisecond*100)
+		}())
+
+		close(chCloseCh)
+		assertReady(t, stateCh)
+		time.Sleep(time.Millisecond * 20)
+
+		resultCh := p.Go(publisher.Message{})
+		secondConfirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+		require.EqualError(t, waitResult(resultCh, time.Millisecond*100), "confirmation: nack")
+
+		p.Close()
+		assertClosed(t, p)
+	})
 }
 
-func chCloseChStub(chs ...interface{}) func(chan *amqp.Error) chan *amqp.Error {
-	index := 0
-	return func(ch chan *amqp.Error) chan *amqp.Error {
-		switch curr := chs[index].(type) {
-		case chan *amqp.Error:
-			index++
-			return curr
-		default:
-			panic(fmt.Sprintf("unexpected type given: %T", chs[index]))
+func TestReturns(main *testing.T) {
+	main.Run("ErrorIfReturnWaitLessThanZero", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		connCh := make(chan *publisher.Connection)
+		_, err := publisher.New(connCh, publisher.WithReturnWait(-time.Second))
+		require.EqualError(t, err, "return wait must be greater or equal to 0")
+	})
+
+	main.Run("ReturnedWithConfirmation", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 2)
+		returnCh := make(chan amqp.Return, 1)
+		messageIDCh := make(chan string, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			NotifyReturn(any()).
+			Return(returnCh).
+			Times(1)
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(confirmationCh)).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, mandatory, _ bool, msg amqp.Publishing) error {
+				require.True(t, mandatory)
+				messageIDCh <- msg.MessageId
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(2),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		resultCh := p.Go(publisher.Message{
+			Exchange:  "theExchange",
+			Key:       "theKey",
+			Mandatory: true,
+		})
+
+		messageID := <-messageIDCh
+		require.NotEmpty(t, messageID)
+
+		returnCh <- amqp.Return{
+			ReplyCode:  312,
+			ReplyText:  "NO_ROUTE",
+			Exchange:   "theExchange",
+			RoutingKey: "theKey",
+			MessageId:  messageID,
 		}
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+
+		err := waitResult(resultCh, time.Millisecond*100)
+		require.EqualError(t, err, "message returned: 312 NO_ROUTE")
+
+		var returnedErr *publisher.ErrReturned
+		require.True(t, errors.As(err, &returnedErr))
+		require.Equal(t, &publisher.ErrReturned{
+			ReplyCode:  312,
+			ReplyText:  "NO_ROUTE",
+			Exchange:   "theExchange",
+			RoutingKey: "theKey",
+		}, returnedErr)
+
+		close(confirmationCh)
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("ReturnedWithoutConfirmation", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		returnCh := make(chan amqp.Return, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			NotifyReturn(any()).
+			Return(returnCh).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				require.Equal(t, "theMessageId", msg.MessageId)
+				returnCh <- amqp.Return{
+					ReplyCode: 312,
+					ReplyText: "NO_ROUTE",
+					MessageId: msg.MessageId,
+				}
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithReturnWait(time.Second),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		resultCh := p.Go(publisher.Message{
+			Mandatory: true,
+			Publishing: amqp.Publishing{
+				MessageId: "theMessageId",
+			},
+		})
+
+		err := waitResult(resultCh, time.Millisecond*100)
+		require.EqualError(t, err, "message returned: 312 NO_ROUTE")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("NotReturnedWithoutConfirmation", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			NotifyReturn(any()).
+			Return(make(chan amqp.Return)).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			Return(nil).
+			Times(1)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithReturnWait(time.Millisecond*50),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		resultCh := p.Go(publisher.Message{Mandatory: true})
+
+		require.EqualError(t, waitResult(resultCh, time.Millisecond*20), "wait result timeout")
+		require.NoError(t, waitResult(resultCh, time.Millisecond*100))
+
+		p.Close()
+		assertClosed(t, p)
+	})
+	main.Run("ReturnNotWaitedByDefault", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		returnCh := make(chan amqp.Return, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			NotifyReturn(any()).
+			Return(returnCh).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				returnCh <- amqp.Return{
+					ReplyCode: 312,
+					ReplyText: "NO_ROUTE",
+					MessageId: msg.MessageId,
+				}
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		require.NoError(t, waitResult(p.Go(publisher.Message{Mandatory: true}), time.Millisecond*20))
+
+		p.Close()
+		assertClosed(t, p)
+	})
+}
+
+func TestRepublishOnReconnect(main *testing.T) {
+	main.Run("ErrorIfAttemptsLessThanOne", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		connCh := make(chan *publisher.Connection)
+		_, err := publisher.New(connCh, publisher.WithRepublishOnReconnect(0))
+		require.EqualError(t, err, "republish attempts must be greater than 0")
+	})
+
+	main.Run("RepublishUnconfirmed", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 2)
+		chCloseCh := make(chan *amqp.Error, 1)
+		secondConfirmationCh := make(chan amqp.Confirmation, 2)
+		secondChCloseCh := make(chan *amqp.Error, 1)
+
+		publishedCh := make(chan string, 3)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(confirmationCh, secondConfirmationCh)).
+			Times(2)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				publishedCh <- string(msg.Body)
+
+				return nil
+			}).
+			Times(3)
+		ch.EXPECT().
+			NotifyClose(any()).
+			DoAndReturn(chCloseChStub(chCloseCh, secondChCloseCh)).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(2),
+			publisher.WithRepublishOnReconnect(1),
+			publisher.WithInitFunc(initFuncStub(ch, ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		firstResultCh := p.Go(publisher.Message{Publishing: amqp.Publishing{Body: []byte("first")}})
+		secondResultCh := p.Go(publisher.Message{Publishing: amqp.Publishing{Body: []byte("second")}})
+		require.Equal(t, "first", <-publishedCh)
+		require.Equal(t, "second", <-publishedCh)
+
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		require.NoError(t, waitResult(firstResultCh, time.Millisecond*100))
+
+		close(chCloseCh)
+		assertReady(t, stateCh)
+		require.Equal(t, "second", <-publishedCh)
+
+		secondConfirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		require.NoError(t, waitResult(secondResultCh, time.Millisecond*100))
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("RepublishMoreThanConfirmationBuffer", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 1)
+		chCloseCh := make(chan *amqp.Error, 1)
+		secondConfirmationCh := make(chan amqp.Confirmation, 3)
+		secondChCloseCh := make(chan *amqp.Error, 1)
+
+		publishedCh := make(chan string, 7)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(confirmationCh, secondConfirmationCh)).
+			Times(2)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				publishedCh <- string(msg.Body)
+
+				return nil
+			}).
+			Times(7)
+		ch.EXPECT().
+			NotifyClose(any()).
+			DoAndReturn(chCloseChStub(chCloseCh, secondChCloseCh)).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(2),
+			publisher.WithRepublishOnReconnect(1),
+			publisher.WithInitFunc(initFuncStub(ch, ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		resultChs := make(map[string]<-chan error)
+		for _, body := range []string{"first", "second", "third"} {
+			resultChs[body] = p.Go(publisher.Message{Publishing: amqp.Publishing{Body: []byte(body)}})
+			require.Equal(t, body, <-publishedCh)
+		}
+
+		// the second is confirmed out of order, the first one stays pending beside the buffered third and fourth.
+		confirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+		require.NoError(t, waitResult(resultChs["second"], time.Millisecond*100))
+
+		resultChs["fourth"] = p.Go(publisher.Message{Publishing: amqp.Publishing{Body: []byte("fourth")}})
+		require.Equal(t, "fourth", <-publishedCh)
+
+		close(chCloseCh)
+		assertReady(t, stateCh)
+		require.Equal(t, "first", <-publishedCh)
+		require.Equal(t, "third", <-publishedCh)
+		require.Equal(t, "fourth", <-publishedCh)
+
+		secondConfirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		secondConfirmationCh <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+		secondConfirmationCh <- amqp.Confirmation{DeliveryTag: 3, Ack: true}
+		for _, body := range []string{"first", "third", "fourth"} {
+			require.NoError(t, waitResult(resultChs[body], time.Millisecond*100), body)
+		}
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("AttemptsExhausted", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		chCloseCh := make(chan *amqp.Error, 1)
+		secondChCloseCh := make(chan *amqp.Error, 1)
+		thirdChCloseCh := make(chan *amqp.Error, 1)
+
+		publishedCh := make(chan struct{}, 2)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(
+				make(chan amqp.Confirmation, 1),
+				make(chan amqp.Confirmation, 1),
+				make(chan amqp.Confirmation, 1),
+			)).
+			Times(3)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+				publishedCh <- struct{}{}
+
+				return nil
+			}).
+			Times(2)
+		ch.EXPECT().
+			NotifyClose(any()).
+			DoAndReturn(chCloseChStub(chCloseCh, secondChCloseCh, thirdChCloseCh)).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(1),
+			publisher.WithRepublishOnReconnect(1),
+			publisher.WithInitFunc(initFuncStub(ch, ch, ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		resultCh := p.Go(publisher.Message{})
+		<-publishedCh
+
+		close(chCloseCh)
+		assertReady(t, stateCh)
+		<-publishedCh
+		require.EqualError(t, waitResult(resultCh, time.Millisecond*50), "wait result timeout")
+
+		close(secondChCloseCh)
+		require.EqualError(t, waitResult(resultCh, time.Millisecond*100), amqp.ErrClosed.Error())
+		assertReady(t, stateCh)
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("MessageContextDone", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		chCloseCh := make(chan *amqp.Error, 1)
+		connCloseCh := make(chan struct{})
+
+		publishedCh := make(chan struct{}, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(
+				make(chan amqp.Confirmation, 1),
+				make(chan amqp.Confirmation, 1),
+			)).
+			Times(2)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+				publishedCh <- struct{}{}
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().
+			NotifyClose(any()).
+			DoAndReturn(chCloseChStub(chCloseCh, make(chan *amqp.Error, 1))).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil).AnyTimes()
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(1),
+			publisher.WithRepublishOnReconnect(3),
+			publisher.WithInitFunc(initFuncStub(ch, ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, connCloseCh)
+		assertReady(t, stateCh)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		resultCh := p.Go(publisher.Message{Context: ctx})
+		<-publishedCh
+
+		close(connCloseCh)
+		assertUnready(t, stateCh, amqp.ErrClosed.Error())
+		require.EqualError(t, waitResult(resultCh, time.Millisecond*50), "wait result timeout")
+
+		cancel()
+		require.EqualError(t, waitResult(resultCh, time.Millisecond*100), "message: context canceled")
+
+		secondAMQPConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(secondAMQPConn, nil)
+		assertReady(t, stateCh)
+
+		p.Close()
+		assertClosed(t, p)
+	})
+}
+
+func TestPublishBatch(main *testing.T) {
+	main.Run("EmptyBatch", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, _, p := newPublisher()
+		defer p.Close()
+
+		require.Empty(t, p.PublishBatch(context.Background(), nil))
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("PublisherClosed", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, _, p := newPublisher()
+		p.Close()
+		assertClosed(t, p)
+
+		results := p.PublishBatch(context.Background(), []publisher.Message{{}, {}})
+		require.Len(t, results, 2)
+		require.EqualError(t, results[0], "publisher stopped")
+		require.EqualError(t, results[1], "publisher stopped")
+	})
+
+	main.Run("ErrOnUnready", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, _, p := newPublisher()
+		defer p.Close()
+
+		results := p.PublishBatch(context.Background(), []publisher.Message{{ErrOnUnready: true}, {}})
+		require.Len(t, results, 2)
+		require.EqualError(t, results[0], "publisher not ready")
+		require.EqualError(t, results[1], "publisher not ready")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("ContextDoneWhileUnready", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, _, p := newPublisher()
+		defer p.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		results := p.PublishBatch(ctx, []publisher.Message{{}})
+		require.Len(t, results, 1)
+		require.EqualError(t, results[0], "batch: context deadline exceeded")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("ResultsInOrderOfMessages", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		confirmationCh := make(chan amqp.Confirmation, 3)
+		publishedCh := make(chan string, 3)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyPublish(any()).
+			DoAndReturn(confirmationChStub(confirmationCh)).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				publishedCh <- string(msg.Body)
+
+				return nil
+			}).
+			Times(3)
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Confirm(false).Return(nil)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithConfirmation(3),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		go func() {
+			for tag := uint64(1); tag <= 3; tag++ {
+				<-publishedCh
+				confirmationCh <- amqp.Confirmation{DeliveryTag: tag, Ack: tag != 2}
+			}
+		}()
+
+		results := p.PublishBatch(context.Background(), []publisher.Message{
+			{Publishing: amqp.Publishing{Body: []byte("first")}},
+			{Publishing: amqp.Publishing{Body: []byte("second")}},
+			{Publishing: amqp.Publishing{Body: []byte("third")}},
+		})
+		require.Len(t, results, 3)
+		require.NoError(t, results[0])
+		require.EqualError(t, results[1], "confirmation: nack")
+		require.NoError(t, results[2])
+
+		close(confirmationCh)
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("WaitWhileFlowPaused", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		chFlowCh := make(chan bool, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyClose(any()).
+			AnyTimes()
+		ch.EXPECT().
+			NotifyFlow(any()).
+			Return(chFlowCh).
+			Times(1)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			Return(nil).
+			Times(2)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		chFlowCh <- false
+		assertUnready(t, stateCh, "publisher flow paused")
+
+		resultsCh := make(chan []error, 1)
+		go func() {
+			resultsCh <- p.PublishBatch(context.Background(), []publisher.Message{{}, {}})
+		}()
+
+		select {
+		case <-resultsCh:
+			t.Fatal("batch must not be published while flow paused")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		chFlowCh <- true
+		assertReady(t, stateCh)
+
+		select {
+		case results := <-resultsCh:
+			require.Equal(t, []error{nil, nil}, results)
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("batch must be published")
+		}
+
+		p.Close()
+		assertClosed(t, p)
+	})
+}
+
+func TestTransactions(main *testing.T) {
+	main.Run("ErrorIfUsedWithConfirmation", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		connCh := make(chan *publisher.Connection)
+		_, err := publisher.New(connCh, publisher.WithConfirmation(1), publisher.WithTransactions())
+		require.EqualError(t, err, "confirmation and transactions could not be used together")
+	})
+
+	main.Run("ErrorIfNotEnabled", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, _, p := newPublisher()
+		defer p.Close()
+
+		err := p.PublishTx(context.Background(), []publisher.Message{{}})
+		require.EqualError(t, err, "transactions are not enabled")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("WaitRetryIfTxErrored", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(fmt.Errorf("the error")).Times(1)
+		ch.EXPECT().Close().Times(1)
+		defer ch.Close()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, l, p := newPublisher(
+			publisher.WithNotify(stateCh),
+			publisher.WithRestartSleep(time.Millisecond*50),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithTransactions(),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+
+		assertUnready(t, stateCh, "the error")
+		p.Close()
+		assertClosed(t, p)
+
+		expected := `[DEBUG] publisher starting
+[DEBUG] publisher stopped
+`
+		require.Equal(t, expected, l.Logs())
+	})
+
+	main.Run("Committed", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(nil).Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				Publish("theExchange", "firstKey", false, false, any()).
+				Return(nil).
+				Times(1),
+			ch.EXPECT().
+				Publish("theExchange", "secondKey", false, false, any()).
+				Return(nil).
+				Times(1),
+			ch.EXPECT().TxCommit().Return(nil).Times(1),
+		)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithTransactions(),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		err := p.PublishTx(context.Background(), []publisher.Message{
+			{Exchange: "theExchange", Key: "firstKey"},
+			{Exchange: "theExchange", Key: "secondKey"},
+		})
+		require.NoError(t, err)
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("RolledBackOnPublishError", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(nil).Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				Return(nil).
+				Times(1),
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				Return(fmt.Errorf("the error")).
+				Times(1),
+			ch.EXPECT().TxRollback().Return(nil).Times(1),
+		)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithTransactions(),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		err := p.PublishTx(context.Background(), []publisher.Message{{}, {}, {}})
+		require.EqualError(t, err, "tx: publish: the error")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("RolledBackOnContextDone", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(nil).Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				DoAndReturn(func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+					cancel()
+
+					return nil
+				}).
+				Times(1),
+			ch.EXPECT().TxRollback().Return(nil).Times(1),
+		)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithTransactions(),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		err := p.PublishTx(ctx, []publisher.Message{{}, {}})
+		require.EqualError(t, err, "tx: context canceled")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("RolledBackOnContextDoneBeforeCommit", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(nil).Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				Return(nil).
+				Times(1),
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				DoAndReturn(func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+					cancel()
+
+					return nil
+				}).
+				Times(1),
+			ch.EXPECT().TxRollback().Return(nil).Times(1),
+		)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithTransactions(),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		err := p.PublishTx(ctx, []publisher.Message{{}, {}})
+		require.EqualError(t, err, "tx: context canceled")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("ChannelClosedBeforeCommit", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		chCloseCh := make(chan *amqp.Error, 1)
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().
+			NotifyClose(any()).
+			DoAndReturn(chCloseChStub(chCloseCh, make(chan *amqp.Error, 1))).
+			AnyTimes()
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(nil).Times(2)
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			Return(nil).
+			Times(2)
+		ch.EXPECT().
+			TxCommit().
+			DoAndReturn(func() error {
+				close(chCloseCh)
+
+				return amqp.ErrClosed
+			}).
+			Times(1)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, l, p := newPublisher(
+			publisher.WithTransactions(),
+			publisher.WithInitFunc(initFuncStub(ch, ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		err := p.PublishTx(context.Background(), []publisher.Message{{}, {}})
+		require.EqualError(t, err, "tx: commit: "+amqp.ErrClosed.Error())
+
+		assertReady(t, stateCh)
+		p.Close()
+		assertClosed(t, p)
+
+		expected := `[DEBUG] publisher starting
+[DEBUG] publisher ready
+[DEBUG] channel closed
+[DEBUG] publisher ready
+[DEBUG] publisher stopped
+`
+		require.Equal(t, expected, l.Logs())
+	})
+
+	main.Run("PublishCommittedOneByOne", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().NotifyFlow(any()).AnyTimes()
+		ch.EXPECT().Tx().Return(nil).Times(1)
+		gomock.InOrder(
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				Return(nil).
+				Times(1),
+			ch.EXPECT().TxCommit().Return(nil).Times(1),
+			ch.EXPECT().
+				Publish(any(), any(), any(), any(), any()).
+				Return(nil).
+				Times(1),
+			ch.EXPECT().TxCommit().Return(fmt.Errorf("the error")).Times(1),
+		)
+		ch.EXPECT().Close().AnyTimes()
+
+		stateCh := make(chan publisher.State, 2)
+
+		connCh, _, p := newPublisher(
+			publisher.WithTransactions(),
+			publisher.WithInitFunc(initFuncStub(ch)),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		require.NoError(t, p.Publish(publisher.Message{}))
+		require.EqualError(t, p.Publish(publisher.Message{}), "the error")
+
+		p.Close()
+		assertClosed(t, p)
+	})
+}
+
+func TestChannelPool(main *testing.T) {
+	main.Run("ErrorIfSizeLessThanOne", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		connCh := make(chan *publisher.Connection)
+		_, err := publisher.New(connCh, publisher.WithChannelPoolSize(0))
+		require.EqualError(t, err, "channel pool size must be greater than 0")
+	})
+
+	main.Run("PublishInParallel", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan publisher.State, 2)
+		blockCh := make(chan struct{})
+
+		ch0 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch0.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch0.EXPECT().NotifyClose(any()).DoAndReturn(notifyCloseStub()).Times(1)
+		ch0.EXPECT().NotifyFlow(any()).DoAndReturn(notifyFlowStub()).Times(1)
+		ch0.EXPECT().Close().Return(nil).Times(1)
+
+		ch1 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch1.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch1.EXPECT().NotifyClose(any()).DoAndReturn(notifyCloseStub()).Times(1)
+		ch1.EXPECT().NotifyFlow(any()).DoAndReturn(notifyFlowStub()).Times(1)
+		ch1.EXPECT().Close().Return(nil).Times(1)
+
+		blockingPublish := func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+			<-blockCh
+			return nil
+		}
+		ch0.EXPECT().Publish(any(), any(), any(), any(), any()).DoAndReturn(blockingPublish).MaxTimes(1)
+		ch1.EXPECT().Publish(any(), any(), any(), any(), any()).DoAndReturn(blockingPublish).MaxTimes(1)
+
+		connCh, _, p := newPublisher(
+			publisher.WithInitFunc(concurrentInitFuncStub(ch0, ch1)),
+			publisher.WithChannelPoolSize(2),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+
+		resultCh0 := p.Go(publisher.Message{})
+		resultCh1 := p.Go(publisher.Message{})
+
+		require.EqualError(t, waitResult(resultCh0, time.Millisecond*50), "wait result timeout")
+		require.EqualError(t, waitResult(resultCh1, time.Millisecond*50), "wait result timeout")
+
+		close(blockCh)
+
+		require.NoError(t, waitResult(resultCh0, time.Millisecond*50))
+		require.NoError(t, waitResult(resultCh1, time.Millisecond*50))
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("FlowPausedOnOneChannel", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan publisher.State, 2)
+		chFlowCh := make(chan bool, 1)
+
+		ch0 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch0.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch0.EXPECT().NotifyClose(any()).DoAndReturn(notifyCloseStub()).Times(1)
+		ch0.EXPECT().NotifyFlow(any()).Return(chFlowCh).Times(1)
+		ch0PublishedCh := make(chan struct{}, 1)
+		ch0.EXPECT().Publish(any(), any(), any(), any(), any()).DoAndReturn(publishedStub(ch0PublishedCh)).AnyTimes()
+		ch0.EXPECT().Close().Return(nil).Times(1)
+
+		var ch1Published int32
+		ch1PublishedCh := make(chan struct{}, 1)
+		ch1 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch1.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch1.EXPECT().NotifyClose(any()).DoAndReturn(notifyCloseStub()).Times(1)
+		ch1.EXPECT().NotifyFlow(any()).DoAndReturn(notifyFlowStub()).Times(1)
+		ch1.EXPECT().Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+				atomic.AddInt32(&ch1Published, 1)
+
+				return publishedStub(ch1PublishedCh)(exchange, key, mandatory, immediate, msg)
+			}).AnyTimes()
+		ch1.EXPECT().Close().Return(nil).Times(1)
+
+		connCh, l, p := newPublisher(
+			publisher.WithInitFunc(concurrentInitFuncStub(ch0, ch1)),
+			publisher.WithChannelPoolSize(2),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+		waitPoolReady(t, p, ch0PublishedCh, ch1PublishedCh)
+
+		chFlowCh <- false
+		var logs string
+		require.Eventually(t, func() bool {
+			logs += l.Logs()
+
+			return strings.Contains(logs, "[WARN] publisher flow paused\n")
+		}, time.Millisecond*100, time.Millisecond)
+
+		published := atomic.LoadInt32(&ch1Published)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, waitResult(p.Go(publisher.Message{ErrOnUnready: true}), time.Millisecond*50))
+		}
+		require.Equal(t, published+3, atomic.LoadInt32(&ch1Published))
+
+		assertNoStateChanged(t, stateCh)
+
+		p.Close()
+		assertClosed(t, p)
+	})
+
+	main.Run("ChannelClosedWhileOthersPublish", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		stateCh := make(chan publisher.State, 2)
+		chCloseCh := make(chan *amqp.Error, 1)
+
+		ch0 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch0.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch0.EXPECT().NotifyClose(any()).Return(chCloseCh).Times(1)
+		ch0.EXPECT().NotifyFlow(any()).DoAndReturn(notifyFlowStub()).Times(1)
+		ch0PublishedCh := make(chan struct{}, 1)
+		ch0.EXPECT().Publish(any(), any(), any(), any(), any()).DoAndReturn(publishedStub(ch0PublishedCh)).AnyTimes()
+
+		ch1 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch1.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch1.EXPECT().NotifyClose(any()).DoAndReturn(notifyCloseStub()).Times(1)
+		ch1.EXPECT().NotifyFlow(any()).DoAndReturn(notifyFlowStub()).Times(1)
+		ch1PublishedCh := make(chan struct{}, 1)
+		ch1.EXPECT().Publish(any(), any(), any(), any(), any()).DoAndReturn(publishedStub(ch1PublishedCh)).AnyTimes()
+		ch1.EXPECT().Close().Return(nil).Times(1)
+
+		ch2 := mock_publisher.NewMockAMQPChannel(ctrl)
+		ch2.EXPECT().NotifyReturn(any()).AnyTimes()
+		ch2.EXPECT().NotifyClose(any()).DoAndReturn(notifyCloseStub()).Times(1)
+		ch2.EXPECT().NotifyFlow(any()).DoAndReturn(notifyFlowStub()).Times(1)
+		ch2.EXPECT().Publish(any(), any(), any(), any(), any()).Return(nil).AnyTimes()
+		ch2.EXPECT().Close().Return(nil).Times(1)
+
+		connCh, _, p := newPublisher(
+			publisher.WithInitFunc(concurrentInitFuncStub(ch0, ch1, ch2)),
+			publisher.WithChannelPoolSize(2),
+			publisher.WithNotify(stateCh),
+		)
+		defer p.Close()
+
+		amqpConn := mock_publisher.NewMockAMQPConnection(ctrl)
+		connCh <- publisher.NewConnection(amqpConn, nil)
+		assertReady(t, stateCh)
+		waitPoolReady(t, p, ch0PublishedCh, ch1PublishedCh)
+
+		chCloseCh <- amqp.ErrClosed
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, waitResult(p.Go(publisher.Message{}), time.Millisecond*50))
+		}
+
+		assertNoStateChanged(t, stateCh)
+
+		p.Close()
+		assertClosed(t, p)
+	})
+}
+
+// waitPoolReady publishes until every channel of the pool got a message.
+// A channel publishes only once it is ready, so all of them are ready when it returns.
+func waitPoolReady(t *testing.T, p *publisher.Publisher, publishedChs ...<-chan struct{}) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	for _, publishedCh := range publishedChs {
+		for published := false; !published; {
+			select {
+			case <-publishedCh:
+				published = true
+			case <-timer.C:
+				t.Fatal("publisher pool must be ready")
+			default:
+				require.NoError(t, p.Publish(publisher.Message{}))
+			}
+		}
+	}
+}
+
+func publishedStub(publishedCh chan struct{}) func(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return func(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+		select {
+		case publishedCh <- struct{}{}:
+		default:
+		}
+
+		return nil
+	}
+}
+
+func assertClosed(t *testing.T, p *publisher.Publisher) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	select {
+	case <-p.NotifyClosed():
+	case <-timer.C:
+		t.Fatal("publisher close timeout")
+	}
+}
+
+func assertUnready(t *testing.T, stateCh <-chan publisher.State, errString string) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	select {
+	case state := <-stateCh:
+
+		require.Nil(t, state.Ready, fmt.Sprintf("%+v", state))
+
+		require.NotNil(t, state.Unready, fmt.Sprintf("%+v", state))
+
+		require.EqualError(t, state.Unready.Err, errString)
+	case <-timer.C:
+		t.Fatal("publisher must be unready")
+	}
+}
+
+func assertReady(t *testing.T, stateCh <-chan publisher.State) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	select {
+	case state := <-stateCh:
+
+		require.Nil(t, state.Unready, fmt.Sprintf("%+v", state))
+
+		require.NotNil(t, state.Ready, fmt.Sprintf("%+v", state))
+	case <-timer.C:
+		t.Fatal("publisher must be ready")
+	}
+}
+
+func assertNoStateChanged(t *testing.T, stateCh <-chan publisher.State) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+	select {
+	case <-stateCh:
+		t.Fatal("state change is not expected")
+	case <-timer.C:
+	}
+}
+
+func any() gomock.Matcher {
+	return gomock.Any()
+}
+
+func newPublisher(opts ...publisher.Option) (connCh chan *publisher.Connection, l *logger.TestLogger, p *publisher.Publisher) {
+	connCh = make(chan *publisher.Connection, 1)
+
+	l = logger.NewTest()
+	opts = append(opts, publisher.WithLogger(l))
+
+	p, err := publisher.New(connCh, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return connCh, l, p
+}
+
+func waitResult(resultCh <-chan error, dur time.Duration) error {
+	timer := time.NewTimer(dur)
+	defer timer.Stop()
+
+	select {
+	case res := <-resultCh:
+		return res
+	case <-timer.C:
+		return fmt.Errorf("wait result timeout")
+	}
+}
+
+func notifyCloseStub() func(_ chan *amqp.Error) chan *amqp.Error {
+	return func(ch chan *amqp.Error) chan *amqp.Error {
+		return ch
+	}
+}
+
+func notifyFlowStub() func(_ chan bool) chan bool {
+	return func(ch chan bool) chan bool {
+		return ch
+	}
+}
+
+func confirmationChStub(chs ...interface{}) func(chan amqp.Confirmation) chan amqp.Confirmation {
+	index := 0
+	return func(ch chan amqp.Confirmation) chan amqp.Confirmation {
+		switch curr := chs[index].(type) {
+		case chan amqp.Confirmation:
+			index++
+			return curr
+		default:
+			panic(fmt.Sprintf("unexpected type given: %T", chs[index]))
+		}
+	}
+}
+
+func chCloseChStub(chs ...interface{}) func(chan *amqp.Error) chan *amqp.Error {
+	index := 0
+	return func(ch chan *amqp.Error) chan *amqp.Error {
+		switch curr := chs[index].(type) {
+		case chan *amqp.Error:
+			index++
+			return curr
+		default:
+			panic(fmt.Sprintf("unexpected type given: %T", chs[index]))
+		}
+	}
+}
+
+func concurrentInitFuncStub(chs ...interface{}) func(publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+	mu := &sync.Mutex{}
+	initFunc := initFuncStub(chs...)
+	return func(conn publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return initFunc(conn)
 	}
 }
 
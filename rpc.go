@@ -0,0 +1,75 @@
+//nolint:dupl // ignore linter err
+package amqpextra
+
+import (
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/makasim/amqpextra/rpc"
+)
+
+func NewRPCClient(
+	connCh <-chan *Connection,
+	opts ...rpc.ClientOption,
+) (*rpc.Client, error) {
+	rpcConnCh := make(chan *rpc.Connection)
+
+	c, err := rpc.NewClient(rpcConnCh, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go proxyRPCConn(connCh, rpcConnCh, c.NotifyClosed())
+
+	return c, nil
+}
+
+func NewRPCServer(
+	connCh <-chan *Connection,
+	handler rpc.Handler,
+	opts ...rpc.ServerOption,
+) (*rpc.Server, error) {
+	consumerConnCh := make(chan *consumer.Connection)
+	publisherConnCh := make(chan *publisher.Connection)
+
+	s, err := rpc.NewServer(consumerConnCh, publisherConnCh, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go proxyConsumerConn(connCh, consumerConnCh, s.NotifyClosed())
+	go proxyPublisherConn(connCh, publisherConnCh, s.NotifyClosed())
+
+	return s, nil
+}
+
+//nolint:dupl // ignore linter err
+func proxyRPCConn(
+	connCh <-chan *Connection,
+	rpcConnCh chan *rpc.Connection,
+	rpcCloseCh <-chan struct{},
+) {
+	go func() {
+		defer close(rpcConnCh)
+
+		for {
+			select {
+			case conn, ok := <-connCh:
+				if !ok {
+					return
+				}
+
+				rpcConn := rpc.NewConnection(conn.amqpConn, conn.NotifyLost())
+
+				select {
+				case rpcConnCh <- rpcConn:
+				case <-conn.NotifyLost():
+					continue
+				case <-rpcCloseCh:
+					return
+				}
+			case <-rpcCloseCh:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,341 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/makasim/amqpextra/backoff"
+	"github.com/makasim/amqpextra/logger"
+	"github.com/streadway/amqp"
+)
+
+// DirectReplyTo is the pseudo queue RabbitMQ delivers replies to without a reply queue being declared.
+// See https://www.rabbitmq.com/direct-reply-to.html
+const DirectReplyTo = "amq.rabbitmq.reply-to"
+
+var errChannelClosed = fmt.Errorf("channel closed")
+
+type AMQPConnection interface {
+}
+
+type AMQPChannel interface {
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Message is a request sent by Client.Call.
+// Publishing.ReplyTo and Publishing.CorrelationId are set by the client.
+type Message struct {
+	Exchange   string
+	Key        string
+	Publishing amqp.Publishing
+}
+
+type ClientOption func(c *Client)
+
+type call struct {
+	correlationID string
+	msg           Message
+	resultCh      chan result
+}
+
+type result struct {
+	delivery amqp.Delivery
+	err      error
+}
+
+// Client sends requests and waits for replies on the direct reply-to pseudo queue.
+// Requests are published on the same channel the replies are consumed from, as direct reply-to requires.
+type Client struct {
+	connCh <-chan *Connection
+
+	ctx          context.Context
+	cancelFunc   context.CancelFunc
+	retryPeriod  time.Duration
+	backoff      backoff.Backoff
+	stablePeriod time.Duration
+	retry        *backoff.Retry
+	initFunc     func(conn AMQPConnection) (AMQPChannel, error)
+	logger       logger.Logger
+
+	correlationIDPrefix string
+	correlationIDSeq    uint64
+
+	callCh   chan call
+	cancelCh chan string
+	closeCh  chan struct{}
+}
+
+func NewClient(connCh <-chan *Connection, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		connCh: connCh,
+
+		callCh:   make(chan call),
+		cancelCh: make(chan string),
+		closeCh:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.ctx != nil {
+		c.ctx, c.cancelFunc = context.WithCancel(c.ctx)
+	} else {
+		c.ctx, c.cancelFunc = context.WithCancel(context.Background())
+	}
+
+	if c.retryPeriod == 0 {
+		c.retryPeriod = time.Second * 5
+	}
+
+	if c.retryPeriod < 0 {
+		return nil, fmt.Errorf("retryPeriod must be greater then zero")
+	}
+
+	if c.backoff == nil {
+		c.backoff = backoff.Constant(c.retryPeriod)
+	}
+	c.retry = backoff.NewRetry(c.backoff, c.stablePeriod)
+
+	if c.logger == nil {
+		c.logger = logger.Discard
+	}
+
+	if c.initFunc == nil {
+		c.initFunc = func(conn AMQPConnection) (AMQPChannel, error) {
+			return conn.(*amqp.Connection).Channel()
+		}
+	}
+
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	c.correlationIDPrefix = hex.EncodeToString(prefix)
+
+	go c.connectionState()
+
+	return c, nil
+}
+
+func WithClientLogger(l logger.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+func WithClientContext(ctx context.Context) ClientOption {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+func WithClientRetryPeriod(dur time.Duration) ClientOption {
+	return func(c *Client) {
+		c.retryPeriod = dur
+		c.backoff = nil
+	}
+}
+
+// WithClientBackoff configure how much time to wait before the client retries to set up a channel.
+// The backoff is reset once the client stayed ready for at least stablePeriod.
+func WithClientBackoff(b backoff.Backoff, stablePeriod time.Duration) ClientOption {
+	return func(c *Client) {
+		c.backoff = b
+		c.stablePeriod = stablePeriod
+	}
+}
+
+func WithClientInitFunc(f func(conn AMQPConnection) (AMQPChannel, error)) ClientOption {
+	return func(c *Client) {
+		c.initFunc = f
+	}
+}
+
+// Call publishes the request and returns the reply with the same correlation id.
+// It waits while the client is not ready, until ctx is done.
+// If ctx has a deadline and the request has no expiration, the request expires with the deadline.
+// Pending calls fail with amqp.ErrClosed if the channel or connection is lost.
+func (c *Client) Call(ctx context.Context, msg Message) (amqp.Delivery, error) {
+	if deadline, ok := ctx.Deadline(); ok && msg.Publishing.Expiration == "" {
+		ttl := time.Until(deadline).Milliseconds()
+		if ttl < 1 {
+			return amqp.Delivery{}, fmt.Errorf("call: %v", context.DeadlineExceeded)
+		}
+
+		msg.Publishing.Expiration = strconv.FormatInt(ttl, 10)
+	}
+
+	cl := call{
+		correlationID: fmt.Sprintf("%s-%d", c.correlationIDPrefix, atomic.AddUint64(&c.correlationIDSeq, 1)),
+		msg:           msg,
+		resultCh:      make(chan result, 1),
+	}
+
+	select {
+	case c.callCh <- cl:
+	case <-ctx.Done():
+		return amqp.Delivery{}, fmt.Errorf("call: %v", ctx.Err())
+	case <-c.ctx.Done():
+		return amqp.Delivery{}, fmt.Errorf("client stopped")
+	}
+
+	select {
+	case res := <-cl.resultCh:
+		return res.delivery, res.err
+	case <-ctx.Done():
+		// the call is forgotten by the ready state, unless it has already failed the call on the channel loss.
+		select {
+		case c.cancelCh <- cl.correlationID:
+		case <-cl.resultCh:
+		case <-c.closeCh:
+		}
+
+		return amqp.Delivery{}, fmt.Errorf("call: %v", ctx.Err())
+	}
+}
+
+func (c *Client) Close() {
+	c.cancelFunc()
+}
+
+func (c *Client) NotifyClosed() <-chan struct{} {
+	return c.closeCh
+}
+
+func (c *Client) connectionState() {
+	defer c.cancelFunc()
+	defer close(c.closeCh)
+	defer c.logger.Printf("[DEBUG] client stopped")
+
+	c.logger.Printf("[DEBUG] client starting")
+	for {
+		select {
+		case conn, ok := <-c.connCh:
+			if !ok {
+				return
+			}
+
+			select {
+			case <-conn.NotifyClose():
+				continue
+			case <-c.ctx.Done():
+				return
+			default:
+			}
+
+			if err := c.channelState(conn.AMQPConnection(), conn.NotifyClose()); err != nil {
+				c.logger.Printf("[DEBUG] client unready")
+				continue
+			}
+
+			return
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) channelState(conn AMQPConnection, connCloseCh <-chan struct{}) error {
+	for {
+		ch, err := c.initFunc(conn)
+		if err != nil {
+			c.logger.Printf("[ERROR] init func: %s", err)
+			return c.waitRetry(err)
+		}
+
+		deliveries, err := ch.Consume(DirectReplyTo, "", true, false, false, false, nil)
+		if err != nil {
+			c.logger.Printf("[ERROR] consume: %s", err)
+			c.close(ch)
+			return c.waitRetry(err)
+		}
+
+		err = c.readyState(ch, deliveries, connCloseCh)
+		if err == errChannelClosed {
+			continue
+		}
+
+		c.close(ch)
+		return err
+	}
+}
+
+func (c *Client) readyState(ch AMQPChannel, deliveries <-chan amqp.Delivery, connCloseCh <-chan struct{}) error {
+	chCloseCh := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	pending := make(map[string]call)
+	defer func() {
+		for _, cl := range pending {
+			cl.resultCh <- result{err: amqp.ErrClosed}
+		}
+	}()
+
+	c.logger.Printf("[DEBUG] client ready")
+	c.retry.Ready()
+	for {
+		select {
+		case cl := <-c.callCh:
+			publishing := cl.msg.Publishing
+			publishing.ReplyTo = DirectReplyTo
+			publishing.CorrelationId = cl.correlationID
+
+			if err := ch.Publish(cl.msg.Exchange, cl.msg.Key, false, false, publishing); err != nil {
+				cl.resultCh <- result{err: err}
+				continue
+			}
+
+			pending[cl.correlationID] = cl
+		case d, ok := <-deliveries:
+			if !ok {
+				c.logger.Printf("[DEBUG] deliveries closed")
+				return errChannelClosed
+			}
+
+			cl, ok := pending[d.CorrelationId]
+			if !ok {
+				c.logger.Printf("[WARN] reply with unknown correlation id %s", d.CorrelationId)
+				continue
+			}
+
+			delete(pending, d.CorrelationId)
+			cl.resultCh <- result{delivery: d}
+		case correlationID := <-c.cancelCh:
+			delete(pending, correlationID)
+		case <-chCloseCh:
+			c.logger.Printf("[DEBUG] channel closed")
+			return errChannelClosed
+		case <-connCloseCh:
+			return amqp.ErrClosed
+		case <-c.ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *Client) waitRetry(err error) error {
+	timer := c.retry.Timer()
+	defer backoff.Stop(timer)
+
+	select {
+	case <-timer.C:
+		return err
+	case <-c.ctx.Done():
+		return nil
+	}
+}
+
+func (c *Client) close(ch AMQPChannel) {
+	if err := ch.Close(); err != nil && !strings.Contains(err.Error(), "channel/connection is not open") {
+		c.logger.Printf("[WARN] client: channel close: %s", err)
+	}
+}
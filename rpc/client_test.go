@@ -0,0 +1,358 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/makasim/amqpextra/logger"
+	"github.com/makasim/amqpextra/rpc"
+	"github.com/makasim/amqpextra/rpc/mock_rpc"
+)
+
+func TestClient(main *testing.T) {
+	main.Run("ReplyMatchedByCorrelationID", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		deliveriesCh := make(chan amqp.Delivery, 2)
+		publishedCh := make(chan amqp.Publishing, 2)
+
+		ch := mock_rpc.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			Consume(rpc.DirectReplyTo, "", true, false, false, false, nil).
+			Return((<-chan amqp.Delivery)(deliveriesCh), nil).
+			Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().
+			Publish("theExchange", "theKey", false, false, any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				publishedCh <- msg
+
+				return nil
+			}).
+			Times(2)
+		ch.EXPECT().Close().Times(1)
+
+		connCh, l, c := newClient(rpc.WithClientInitFunc(initFuncStub(ch)))
+		defer c.Close()
+
+		connCh <- rpc.NewConnection(mock_rpc.NewMockAMQPConnection(ctrl), nil)
+
+		type reply struct {
+			delivery amqp.Delivery
+			err      error
+		}
+		firstReplyCh := make(chan reply, 1)
+		secondReplyCh := make(chan reply, 1)
+		go func() {
+			d, err := c.Call(context.Background(), rpc.Message{
+				Exchange:   "theExchange",
+				Key:        "theKey",
+				Publishing: amqp.Publishing{Body: []byte("first")},
+			})
+			firstReplyCh <- reply{d, err}
+		}()
+		first := <-publishedCh
+		go func() {
+			d, err := c.Call(context.Background(), rpc.Message{
+				Exchange:   "theExchange",
+				Key:        "theKey",
+				Publishing: amqp.Publishing{Body: []byte("second")},
+			})
+			secondReplyCh <- reply{d, err}
+		}()
+		second := <-publishedCh
+
+		require.Equal(t, rpc.DirectReplyTo, first.ReplyTo)
+		require.Equal(t, "first", string(first.Body))
+		require.NotEmpty(t, first.CorrelationId)
+		require.NotEqual(t, first.CorrelationId, second.CorrelationId)
+
+		deliveriesCh <- amqp.Delivery{CorrelationId: second.CorrelationId, Body: []byte("secondReply")}
+		deliveriesCh <- amqp.Delivery{CorrelationId: first.CorrelationId, Body: []byte("firstReply")}
+
+		res := <-firstReplyCh
+		require.NoError(t, res.err)
+		require.Equal(t, "firstReply", string(res.delivery.Body))
+
+		res = <-secondReplyCh
+		require.NoError(t, res.err)
+		require.Equal(t, "secondReply", string(res.delivery.Body))
+
+		c.Close()
+		assertClosed(t, c)
+
+		require.Equal(t, `[DEBUG] client starting
+[DEBUG] client ready
+[DEBUG] client stopped
+`, l.Logs())
+	})
+
+	main.Run("ContextDeadline", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		deliveriesCh := make(chan amqp.Delivery, 1)
+		publishedCh := make(chan amqp.Publishing, 1)
+
+		ch := mock_rpc.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			Consume(any(), any(), any(), any(), any(), any(), any()).
+			Return((<-chan amqp.Delivery)(deliveriesCh), nil).
+			Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+				publishedCh <- msg
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().Close().Times(1)
+
+		connCh, l, c := newClient(rpc.WithClientInitFunc(initFuncStub(ch)))
+		defer c.Close()
+
+		connCh <- rpc.NewConnection(mock_rpc.NewMockAMQPConnection(ctrl), nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+
+		_, err := c.Call(ctx, rpc.Message{})
+		require.EqualError(t, err, "call: context deadline exceeded")
+
+		msg := <-publishedCh
+		expiration, err := strconv.Atoi(msg.Expiration)
+		require.NoError(t, err)
+		require.True(t, expiration > 0 && expiration <= 100, expiration)
+
+		deliveriesCh <- amqp.Delivery{CorrelationId: msg.CorrelationId}
+		time.Sleep(time.Millisecond * 20)
+
+		c.Close()
+		assertClosed(t, c)
+
+		require.Equal(t, `[DEBUG] client starting
+[DEBUG] client ready
+[WARN] reply with unknown correlation id `+msg.CorrelationId+`
+[DEBUG] client stopped
+`, l.Logs())
+	})
+
+	main.Run("WaitWhileNotReady", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, _, c := newClient()
+		defer c.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+
+		_, err := c.Call(ctx, rpc.Message{})
+		require.EqualError(t, err, "call: context deadline exceeded")
+
+		c.Close()
+		assertClosed(t, c)
+	})
+
+	main.Run("PendingCallsFailOnConnectionLost", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		publishedCh := make(chan struct{}, 1)
+
+		ch := mock_rpc.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			Consume(any(), any(), any(), any(), any(), any(), any()).
+			Return(make(<-chan amqp.Delivery), nil).
+			Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+				publishedCh <- struct{}{}
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().Close().Times(1)
+
+		connCh, l, c := newClient(rpc.WithClientInitFunc(initFuncStub(ch)))
+		defer c.Close()
+
+		connCloseCh := make(chan struct{})
+		connCh <- rpc.NewConnection(mock_rpc.NewMockAMQPConnection(ctrl), connCloseCh)
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := c.Call(context.Background(), rpc.Message{})
+			errCh <- err
+		}()
+
+		<-publishedCh
+		close(connCloseCh)
+
+		select {
+		case err := <-errCh:
+			require.Equal(t, amqp.ErrClosed, err)
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("call must fail")
+		}
+
+		c.Close()
+		assertClosed(t, c)
+
+		require.Equal(t, `[DEBUG] client starting
+[DEBUG] client ready
+[DEBUG] client unready
+[DEBUG] client stopped
+`, l.Logs())
+	})
+
+	main.Run("ContextDoneOnConnectionLost", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		connCloseCh := make(chan struct{})
+
+		ch := mock_rpc.NewMockAMQPChannel(ctrl)
+		ch.EXPECT().
+			Consume(any(), any(), any(), any(), any(), any(), any()).
+			Return(make(<-chan amqp.Delivery), nil).
+			Times(1)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().
+			Publish(any(), any(), any(), any(), any()).
+			DoAndReturn(func(_, _ string, _, _ bool, _ amqp.Publishing) error {
+				cancel()
+				close(connCloseCh)
+
+				return nil
+			}).
+			Times(1)
+		ch.EXPECT().Close().Times(1)
+
+		connCh, _, c := newClient(rpc.WithClientInitFunc(initFuncStub(ch)))
+		defer c.Close()
+
+		connCh <- rpc.NewConnection(mock_rpc.NewMockAMQPConnection(ctrl), connCloseCh)
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := c.Call(ctx, rpc.Message{})
+			errCh <- err
+		}()
+
+		select {
+		case err := <-errCh:
+			require.Error(t, err)
+		case <-time.After(time.Millisecond * 100):
+			t.Fatal("call must return")
+		}
+
+		c.Close()
+		assertClosed(t, c)
+	})
+
+	main.Run("ReadyAfterConsumeError", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		ch := mock_rpc.NewMockAMQPChannel(ctrl)
+		gomock.InOrder(
+			ch.EXPECT().
+				Consume(any(), any(), any(), any(), any(), any(), any()).
+				Return(nil, fmt.Errorf("the error")).
+				Times(1),
+			ch.EXPECT().
+				Consume(any(), any(), any(), any(), any(), any(), any()).
+				Return(make(<-chan amqp.Delivery), nil).
+				Times(1),
+		)
+		ch.EXPECT().NotifyClose(any()).AnyTimes()
+		ch.EXPECT().Close().Times(2)
+
+		connCh, l, c := newClient(
+			rpc.WithClientInitFunc(initFuncStub(ch, ch)),
+			rpc.WithClientRetryPeriod(time.Millisecond*20),
+		)
+		defer c.Close()
+
+		amqpConn := mock_rpc.NewMockAMQPConnection(ctrl)
+		connCh <- rpc.NewConnection(amqpConn, nil)
+		time.Sleep(time.Millisecond * 10)
+		connCh <- rpc.NewConnection(amqpConn, nil)
+		time.Sleep(time.Millisecond * 50)
+
+		c.Close()
+		assertClosed(t, c)
+
+		require.Equal(t, `[DEBUG] client starting
+[ERROR] consume: the error
+[DEBUG] client unready
+[DEBUG] client ready
+[DEBUG] client stopped
+`, l.Logs())
+	})
+}
+
+func newClient(opts ...rpc.ClientOption) (connCh chan *rpc.Connection, l *logger.TestLogger, c *rpc.Client) {
+	connCh = make(chan *rpc.Connection, 1)
+
+	l = logger.NewTest()
+	opts = append(opts, rpc.WithClientLogger(l))
+
+	c, err := rpc.NewClient(connCh, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return connCh, l, c
+}
+
+func assertClosed(t *testing.T, c interface{ NotifyClosed() <-chan struct{} }) {
+	timer := time.NewTimer(time.Millisecond * 100)
+	defer timer.Stop()
+
+	select {
+	case <-c.NotifyClosed():
+	case <-timer.C:
+		t.Fatal("must be closed")
+	}
+}
+
+func initFuncStub(chs ...rpc.AMQPChannel) func(rpc.AMQPConnection) (rpc.AMQPChannel, error) {
+	index := 0
+	return func(_ rpc.AMQPConnection) (rpc.AMQPChannel, error) {
+		curr := chs[index]
+		index++
+
+		return curr, nil
+	}
+}
+
+func any() gomock.Matcher {
+	return gomock.Any()
+}
@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/logger"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/streadway/amqp"
+)
+
+// ErrorHeader is the reply header the server puts the handler error to.
+const ErrorHeader = "x-rpc-error"
+
+// Handler handles a request and returns the reply publishing.
+// If an error is returned, the reply carries the error text in ErrorHeader instead.
+type Handler func(ctx context.Context, msg amqp.Delivery) (amqp.Publishing, error)
+
+type ServerOption func(s *Server)
+
+// Server consumes requests, runs them through the handler and publishes replies to ReplyTo.
+// A request is acked once its reply is confirmed, and requeued if the reply could not be published.
+// A request without ReplyTo or CorrelationId is nacked, the client could not match its reply.
+type Server struct {
+	handler Handler
+
+	consumerOpts  []consumer.Option
+	publisherOpts []publisher.Option
+	consumer      *consumer.Consumer
+	publisher     *publisher.Publisher
+
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+	logger     logger.Logger
+
+	closeCh chan struct{}
+}
+
+func NewServer(
+	consumerConnCh <-chan *consumer.Connection,
+	publisherConnCh <-chan *publisher.Connection,
+	handler Handler,
+	opts ...ServerOption,
+) (*Server, error) {
+	s := &Server{
+		handler: handler,
+		closeCh: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.handler == nil {
+		return nil, fmt.Errorf("handler must be not nil")
+	}
+
+	if s.ctx != nil {
+		s.ctx, s.cancelFunc = context.WithCancel(s.ctx)
+	} else {
+		s.ctx, s.cancelFunc = context.WithCancel(context.Background())
+	}
+
+	if s.logger == nil {
+		s.logger = logger.Discard
+	}
+
+	p, err := publisher.New(publisherConnCh, append([]publisher.Option{
+		publisher.WithLogger(s.logger),
+		publisher.WithContext(s.ctx),
+		publisher.WithConfirmation(100),
+	}, s.publisherOpts...)...)
+	if err != nil {
+		s.cancelFunc()
+		return nil, fmt.Errorf("publisher: %v", err)
+	}
+	s.publisher = p
+
+	c, err := consumer.New(consumerConnCh, append([]consumer.Option{
+		consumer.WithLogger(s.logger),
+		consumer.WithContext(s.ctx),
+		consumer.WithHandler(consumer.HandlerFunc(s.handle)),
+	}, s.consumerOpts...)...)
+	if err != nil {
+		s.cancelFunc()
+		<-p.NotifyClosed()
+		return nil, fmt.Errorf("consumer: %v", err)
+	}
+	s.consumer = c
+
+	go s.waitClosed()
+
+	return s, nil
+}
+
+func WithServerLogger(l logger.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+func WithServerContext(ctx context.Context) ServerOption {
+	return func(s *Server) {
+		s.ctx = ctx
+	}
+}
+
+// WithServerConsumerOptions configures the consumer requests are received with.
+// The queue to consume requests from must be set here.
+func WithServerConsumerOptions(opts ...consumer.Option) ServerOption {
+	return func(s *Server) {
+		s.consumerOpts = append(s.consumerOpts, opts...)
+	}
+}
+
+// WithServerPublisherOptions configures the publisher replies are sent with.
+// Confirmation mode is on by default, do not turn on transactions.
+func WithServerPublisherOptions(opts ...publisher.Option) ServerOption {
+	return func(s *Server) {
+		s.publisherOpts = append(s.publisherOpts, opts...)
+	}
+}
+
+// ReplyError returns the error the server handler failed with, nil if the reply has no error.
+func ReplyError(reply amqp.Delivery) error {
+	if errText, ok := reply.Headers[ErrorHeader].(string); ok {
+		return fmt.Errorf("%s", errText)
+	}
+
+	return nil
+}
+
+func (s *Server) Close() {
+	s.cancelFunc()
+}
+
+func (s *Server) NotifyClosed() <-chan struct{} {
+	return s.closeCh
+}
+
+func (s *Server) waitClosed() {
+	defer close(s.closeCh)
+
+	select {
+	case <-s.consumer.NotifyClosed():
+	case <-s.publisher.NotifyClosed():
+	case <-s.ctx.Done():
+	}
+
+	s.cancelFunc()
+	<-s.consumer.NotifyClosed()
+	<-s.publisher.NotifyClosed()
+}
+
+func (s *Server) handle(ctx context.Context, msg amqp.Delivery) consumer.Result {
+	if msg.ReplyTo == "" {
+		s.logger.Printf("[WARN] rpc server: no reply to")
+
+		return consumer.Nack
+	}
+	if msg.CorrelationId == "" {
+		s.logger.Printf("[WARN] rpc server: no correlation id")
+
+		return consumer.Nack
+	}
+
+	reply, err := s.handler(ctx, msg)
+	if err != nil {
+		reply = amqp.Publishing{
+			Headers: amqp.Table{ErrorHeader: err.Error()},
+		}
+	}
+	reply.CorrelationId = msg.CorrelationId
+
+	if err := s.publisher.Publish(publisher.Message{
+		Context:    ctx,
+		Key:        msg.ReplyTo,
+		Publishing: reply,
+	}); err != nil {
+		s.logger.Printf("[ERROR] rpc server: reply: %s", err)
+
+		return consumer.Requeue
+	}
+
+	return consumer.Ack
+}
@@ -0,0 +1,314 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/makasim/amqpextra/consumer"
+	"github.com/makasim/amqpextra/consumer/mock_consumer"
+	"github.com/makasim/amqpextra/logger"
+	"github.com/makasim/amqpextra/publisher"
+	"github.com/makasim/amqpextra/publisher/mock_publisher"
+	"github.com/makasim/amqpextra/rpc"
+)
+
+func TestServer(main *testing.T) {
+	main.Run("ErrorIfHandlerNil", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, err := rpc.NewServer(nil, nil, nil)
+		require.EqualError(t, err, "handler must be not nil")
+	})
+
+	main.Run("ErrorIfConsumerMisconfigured", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		_, err := rpc.NewServer(
+			make(chan *consumer.Connection),
+			make(chan *publisher.Connection),
+			func(_ context.Context, _ amqp.Delivery) (amqp.Publishing, error) {
+				return amqp.Publishing{}, nil
+			},
+		)
+		require.EqualError(t, err, "consumer: WithQueue or WithQueues or WithExchange or WithDeclareQueue or WithTmpQueue options must be set")
+	})
+
+	main.Run("AckAfterReplyConfirmed", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		env := newServerEnv(ctrl, func(_ context.Context, msg amqp.Delivery) (amqp.Publishing, error) {
+			return amqp.Publishing{Body: append([]byte("reply to "), msg.Body...)}, nil
+		})
+		defer env.s.Close()
+
+		a := newAcknowledger()
+		env.deliveryCh <- amqp.Delivery{
+			Acknowledger:  a,
+			DeliveryTag:   1,
+			ReplyTo:       rpc.DirectReplyTo,
+			CorrelationId: "theCorrelationID",
+			Body:          []byte("request"),
+		}
+
+		reply := <-env.publishedCh
+		require.Equal(t, "theCorrelationID", reply.CorrelationId)
+		require.Equal(t, "reply to request", string(reply.Body))
+		require.Nil(t, reply.Headers)
+		a.assertNoCalls(t)
+
+		env.confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		require.Equal(t, "ack", a.wait(t))
+
+		env.s.Close()
+		assertClosed(t, env.s)
+	})
+
+	main.Run("HandlerErrorReplied", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		env := newServerEnv(ctrl, func(_ context.Context, _ amqp.Delivery) (amqp.Publishing, error) {
+			return amqp.Publishing{Body: []byte("ignored")}, fmt.Errorf("the error")
+		})
+		defer env.s.Close()
+
+		a := newAcknowledger()
+		env.deliveryCh <- amqp.Delivery{
+			Acknowledger:  a,
+			ReplyTo:       rpc.DirectReplyTo,
+			CorrelationId: "theCorrelationID",
+		}
+
+		reply := <-env.publishedCh
+		require.Equal(t, "theCorrelationID", reply.CorrelationId)
+		require.Empty(t, reply.Body)
+		require.EqualError(t, rpc.ReplyError(amqp.Delivery{Headers: reply.Headers}), "the error")
+
+		env.confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		require.Equal(t, "ack", a.wait(t))
+
+		env.s.Close()
+		assertClosed(t, env.s)
+	})
+
+	main.Run("RequeueIfReplyNacked", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		env := newServerEnv(ctrl, func(_ context.Context, _ amqp.Delivery) (amqp.Publishing, error) {
+			return amqp.Publishing{}, nil
+		})
+		defer env.s.Close()
+
+		a := newAcknowledger()
+		env.deliveryCh <- amqp.Delivery{Acknowledger: a, ReplyTo: rpc.DirectReplyTo, CorrelationId: "theCorrelationID"}
+
+		<-env.publishedCh
+		env.confirmationCh <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+		require.Equal(t, "nack requeue", a.wait(t))
+
+		env.s.Close()
+		assertClosed(t, env.s)
+
+		require.Contains(t, env.l.Logs(), "[ERROR] rpc server: reply: confirmation: nack\n")
+	})
+
+	main.Run("RejectIfNoReplyTo", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		env := newServerEnv(ctrl, func(_ context.Context, _ amqp.Delivery) (amqp.Publishing, error) {
+			t.Error("handler must not be called")
+
+			return amqp.Publishing{}, nil
+		})
+		defer env.s.Close()
+
+		a := newAcknowledger()
+		env.deliveryCh <- amqp.Delivery{Acknowledger: a, CorrelationId: "theCorrelationID"}
+
+		require.Equal(t, "nack", a.wait(t))
+
+		env.s.Close()
+		assertClosed(t, env.s)
+
+		require.Contains(t, env.l.Logs(), "[WARN] rpc server: no reply to\n")
+	})
+
+	main.Run("RejectIfNoCorrelationID", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		env := newServerEnv(ctrl, func(_ context.Context, _ amqp.Delivery) (amqp.Publishing, error) {
+			t.Error("handler must not be called")
+
+			return amqp.Publishing{}, nil
+		})
+		defer env.s.Close()
+
+		a := newAcknowledger()
+		env.deliveryCh <- amqp.Delivery{Acknowledger: a, ReplyTo: rpc.DirectReplyTo}
+
+		require.Equal(t, "nack", a.wait(t))
+
+		env.s.Close()
+		assertClosed(t, env.s)
+
+		require.Contains(t, env.l.Logs(), "[WARN] rpc server: no correlation id\n")
+	})
+}
+
+func TestReplyError(t *testing.T) {
+	require.NoError(t, rpc.ReplyError(amqp.Delivery{}))
+	require.EqualError(t, rpc.ReplyError(amqp.Delivery{Headers: amqp.Table{rpc.ErrorHeader: "the error"}}), "the error")
+}
+
+type serverEnv struct {
+	s              *rpc.Server
+	l              *logger.TestLogger
+	deliveryCh     chan amqp.Delivery
+	publishedCh    chan amqp.Publishing
+	confirmationCh chan amqp.Confirmation
+}
+
+func newServerEnv(ctrl *gomock.Controller, h rpc.Handler) *serverEnv {
+	env := &serverEnv{
+		l:              logger.NewTest(),
+		deliveryCh:     make(chan amqp.Delivery, 1),
+		publishedCh:    make(chan amqp.Publishing, 1),
+		confirmationCh: make(chan amqp.Confirmation, 1),
+	}
+
+	consumerCh := mock_consumer.NewMockAMQPChannel(ctrl)
+	consumerCh.EXPECT().Qos(any(), any(), any()).AnyTimes()
+	consumerCh.EXPECT().
+		Consume("theQueue", any(), any(), any(), any(), any(), any()).
+		Return((<-chan amqp.Delivery)(env.deliveryCh), nil).
+		Times(1)
+	consumerCh.EXPECT().NotifyCancel(any()).AnyTimes()
+	consumerCh.EXPECT().NotifyClose(any()).AnyTimes()
+	consumerCh.EXPECT().Close().AnyTimes()
+
+	publisherCh := mock_publisher.NewMockAMQPChannel(ctrl)
+	publisherCh.EXPECT().NotifyReturn(any()).AnyTimes()
+	publisherCh.EXPECT().NotifyClose(any()).AnyTimes()
+	publisherCh.EXPECT().NotifyFlow(any()).AnyTimes()
+	publisherCh.EXPECT().Confirm(false).Return(nil).Times(1)
+	publisherCh.EXPECT().NotifyPublish(any()).Return(env.confirmationCh).Times(1)
+	publisherCh.EXPECT().
+		Publish("", rpc.DirectReplyTo, false, false, any()).
+		DoAndReturn(func(_, _ string, _, _ bool, msg amqp.Publishing) error {
+			env.publishedCh <- msg
+
+			return nil
+		}).
+		AnyTimes()
+	publisherCh.EXPECT().Close().AnyTimes()
+
+	consumerConnCh := make(chan *consumer.Connection, 1)
+	consumerConnCh <- consumer.NewConnection(mock_consumer.NewMockAMQPConnection(ctrl), nil)
+
+	publisherConnCh := make(chan *publisher.Connection, 1)
+	publisherConnCh <- publisher.NewConnection(mock_publisher.NewMockAMQPConnection(ctrl), nil)
+
+	publisherStateCh := make(chan publisher.State, 1)
+
+	s, err := rpc.NewServer(
+		consumerConnCh,
+		publisherConnCh,
+		h,
+		rpc.WithServerLogger(env.l),
+		rpc.WithServerConsumerOptions(
+			consumer.WithQueue("theQueue"),
+			consumer.WithInitFunc(func(_ consumer.AMQPConnection) (consumer.AMQPChannel, error) {
+				return consumerCh, nil
+			}),
+		),
+		rpc.WithServerPublisherOptions(
+			publisher.WithInitFunc(func(_ publisher.AMQPConnection) (publisher.AMQPChannel, error) {
+				return publisherCh, nil
+			}),
+			publisher.WithNotify(publisherStateCh),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	env.s = s
+
+	// the publisher channel is set up before a test could close the server.
+	for state := range publisherStateCh {
+		if state.Ready != nil {
+			break
+		}
+	}
+
+	return env
+}
+
+type acknowledger struct {
+	callCh chan string
+}
+
+func newAcknowledger() *acknowledger {
+	return &acknowledger{callCh: make(chan string, 1)}
+}
+
+func (a *acknowledger) Ack(_ uint64, _ bool) error {
+	a.callCh <- "ack"
+
+	return nil
+}
+
+func (a *acknowledger) Nack(_ uint64, _, requeue bool) error {
+	if requeue {
+		a.callCh <- "nack requeue"
+	} else {
+		a.callCh <- "nack"
+	}
+
+	return nil
+}
+
+func (a *acknowledger) Reject(_ uint64, _ bool) error {
+	a.callCh <- "reject"
+
+	return nil
+}
+
+func (a *acknowledger) wait(t *testing.T) string {
+	select {
+	case call := <-a.callCh:
+		return call
+	case <-time.After(time.Millisecond * 200):
+		t.Fatal("message must be acked or nacked")
+
+		return ""
+	}
+}
+
+func (a *acknowledger) assertNoCalls(t *testing.T) {
+	select {
+	case call := <-a.callCh:
+		t.Fatalf("unexpected %s", call)
+	case <-time.After(time.Millisecond * 50):
+	}
+}